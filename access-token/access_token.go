@@ -1,14 +1,18 @@
 package access_token
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/HeartGarlic/douyin-openapi/cache"
 	"github.com/HeartGarlic/douyin-openapi/util"
-	"sync"
+	"net/http"
 	"time"
 )
 
+// AccessTokenPath 获取token的接口路径
+const AccessTokenPath = "/api/apps/v2/token"
+
 // 正式地址
 const accessTokenURL = "https://developer.toutiao.com/api/apps/v2/token"
 
@@ -17,23 +21,28 @@ const sandBoxTokenURL = "https://open-sandbox.douyin.com/api/apps/v2/token"
 
 // AccessToken 管理AccessToken 的基础接口
 type AccessToken interface {
-	GetCacheKey() string             // 获取缓存的key
-	SetCacheKey(key string)          // 设置缓存key
-	GetAccessToken() (string, error) // 获取token
+	GetCacheKey() string                                       // 获取缓存的key
+	SetCacheKey(key string)                                    // 设置缓存key
+	GetAccessToken() (string, error)                           // 获取token
+	GetAccessTokenContext(ctx context.Context) (string, error) // 获取token 支持传入context
+	Invalidate(staleToken string) error                        // 使缓存的token失效 staleToken 不为空时仅在缓存的token与其相同时失效
+	ForceRefresh(ctx context.Context) (string, error)          // 强制重新获取token
 }
 
-// accessTokenLock 初始化全局锁防止并发获取token
-var accessTokenLock = new(sync.Mutex)
-
 // DefaultAccessToken 默认的token管理类
 type DefaultAccessToken struct {
-	AppId               string      // app_id	string	是	小程序的 app_id
-	AppSecret           string      // app_secret	string	是	小程序的密钥
-	GrantType           string      // grant_type	string	是	固定值“client_credentials”
-	Cache               cache.Cache // 缓存组件
-	accessTokenLock     *sync.Mutex // 读写锁
-	accessTokenCacheKey string      // 缓存的key
-	SandBox             bool        // 是否沙盒地址 默认 false 线上地址
+	AppId             string             // app_id	string	是	小程序的 app_id
+	AppSecret         string             // app_secret	string	是	小程序的密钥
+	GrantType         string             // grant_type	string	是	固定值“client_credentials”
+	Cache             cache.Cache        // 缓存组件
+	SandBox           bool               // 是否沙盒地址 默认 false 线上地址
+	HttpClient        util.Doer          // http客户端 为空时使用默认客户端
+	ApiUrl            string             // 自定义获取token的地址 为空时根据 SandBox 选择
+	Interceptors      []util.Interceptor // 获取token时的请求拦截器
+	Logger            util.Logger        // 日志 记录token刷新 为空时不输出日志
+	LeaseTimeout      time.Duration      // 缓存实现 cache.Locker 时刷新token的租约有效期 默认10秒
+	LeasePollInterval time.Duration      // 等待其他实例刷新token时的轮询间隔 默认100毫秒
+	tokenManager                         // 缓存 合并并发获取和分布式租约
 }
 
 // NewDefaultAccessToken 实例化默认的token管理类
@@ -42,58 +51,46 @@ func NewDefaultAccessToken(appId, appSecret string, cache cache.Cache, IsSandbox
 		panic(any("cache is need"))
 	}
 	token := &DefaultAccessToken{
-		AppId:               appId,
-		AppSecret:           appSecret,
-		GrantType:           "client_credential",
-		Cache:               cache,
-		accessTokenCacheKey: fmt.Sprintf("douyin_openapi_access_token_%s", appId),
-		accessTokenLock:     accessTokenLock,
-		SandBox:             IsSandbox,
+		AppId:     appId,
+		AppSecret: appSecret,
+		GrantType: "client_credential",
+		Cache:     cache,
+		SandBox:   IsSandbox,
 	}
+	token.init(fmt.Sprintf("douyin_openapi_access_token_%s", appId), token.tokenConfig, token.refreshToken)
 	return token
 }
 
-// GetCacheKey 获取缓存key
-func (dd *DefaultAccessToken) GetCacheKey() string {
-	return dd.accessTokenCacheKey
-}
-
-// SetCacheKey 设置缓存key
-func (dd *DefaultAccessToken) SetCacheKey(key string) {
-	dd.accessTokenCacheKey = key
+// tokenConfig 返回 tokenManager 使用的配置
+func (dd *DefaultAccessToken) tokenConfig() tokenConfig {
+	return tokenConfig{cache: dd.Cache, logger: dd.Logger, leaseTimeout: dd.LeaseTimeout, leasePollInterval: dd.LeasePollInterval}
 }
 
-// GetAccessToken 获取token
-func (dd *DefaultAccessToken) GetAccessToken() (string, error) {
-	// 先尝试从缓存中获取如果不存在就调用接口获取
-	if val := dd.Cache.Get(dd.GetCacheKey()); val != nil {
-		return val.(string), nil
-	}
-
-	// 加锁防止并发获取接口
-	dd.accessTokenLock.Lock()
-	defer dd.accessTokenLock.Unlock()
-
-	// 双捡防止重复获取
-	if val := dd.Cache.Get(dd.GetCacheKey()); val != nil {
-		return val.(string), nil
-	}
-
+// refreshToken 调用接口获取token并写入缓存 调用方需要通过 flight 防止并发获取
+func (dd *DefaultAccessToken) refreshToken(ctx context.Context) (string, error) {
 	// 开始调用接口获取token
 	api := accessTokenURL
 	if dd.SandBox {
 		api = sandBoxTokenURL
 	}
-	reqAccessToken, err := GetTokenFromServer(api, dd.AppId, dd.AppSecret)
-	if err != nil {
-		return "", err
+	if dd.ApiUrl != "" {
+		api = dd.ApiUrl
 	}
-	// 设置缓存
-	expires := reqAccessToken.Data.ExpiresIn - 1500
-	err = dd.Cache.Set(dd.GetCacheKey(), reqAccessToken.Data.AccessToken, time.Duration(expires)*time.Second)
+	start := time.Now()
+	logger := util.NewRedactLogger(dd.Logger)
+	invoker := util.ChainInterceptors(util.NewInvoker(dd.HttpClient), dd.Interceptors...)
+	reqAccessToken, err := getTokenFromServer(ctx, invoker, api, dd.AppId, dd.AppSecret)
 	if err != nil {
+		logger.Log(ctx, util.LevelError, "douyin access token refresh failed", util.Field{Key: "app_id", Value: dd.AppId}, util.Field{Key: "error", Value: err})
 		return "", err
 	}
+	logger.Log(ctx, util.LevelInfo, "douyin access token refreshed",
+		util.Field{Key: "app_id", Value: dd.AppId},
+		util.Field{Key: "expires_in", Value: reqAccessToken.Data.ExpiresIn},
+		util.Field{Key: "latency", Value: time.Since(start)},
+	)
+	// 设置缓存
+	storeFetchedToken(ctx, dd.Cache, dd.GetCacheKey(), reqAccessToken.Data.AccessToken, time.Duration(reqAccessToken.Data.ExpiresIn-1500)*time.Second, dd.Logger)
 	return reqAccessToken.Data.AccessToken, nil
 }
 
@@ -111,12 +108,28 @@ type ResAccessTokenData struct {
 
 // GetTokenFromServer 从抖音服务器获取token
 func GetTokenFromServer(apiUrl string, appId, appSecret string) (resAccessToken ResAccessToken, err error) {
+	return GetTokenFromServerWithClient(util.DefaultHttpClient, apiUrl, appId, appSecret)
+}
+
+// GetTokenFromServerWithClient 使用指定的http客户端从抖音服务器获取token
+func GetTokenFromServerWithClient(client util.Doer, apiUrl string, appId, appSecret string) (resAccessToken ResAccessToken, err error) {
+	return GetTokenFromServerContext(context.Background(), client, apiUrl, appId, appSecret)
+}
+
+// GetTokenFromServerContext 使用指定的http客户端和context从抖音服务器获取token
+func GetTokenFromServerContext(ctx context.Context, client util.Doer, apiUrl string, appId, appSecret string) (resAccessToken ResAccessToken, err error) {
+	return getTokenFromServer(ctx, util.NewInvoker(client), apiUrl, appId, appSecret)
+}
+
+// getTokenFromServer 通过 invoker 从抖音服务器获取token
+func getTokenFromServer(ctx context.Context, invoker util.Invoker, apiUrl string, appId, appSecret string) (resAccessToken ResAccessToken, err error) {
 	params := map[string]interface{}{
 		"appid":      appId,
 		"secret":     appSecret,
 		"grant_type": "client_credential",
 	}
-	body, err := util.PostJSON(apiUrl, params)
+	endpoint := util.EndpointName(apiUrl)
+	body, err := invoker(ctx, &util.Request{Endpoint: endpoint, Url: apiUrl, Params: params, Header: http.Header{}})
 	if err != nil {
 		return
 	}
@@ -124,9 +137,6 @@ func GetTokenFromServer(apiUrl string, appId, appSecret string) (resAccessToken
 	if err != nil {
 		return
 	}
-	if resAccessToken.ErrNo != 0 {
-		err = fmt.Errorf("get access_token error : errcode=%v , errormsg=%v", resAccessToken.ErrTips, resAccessToken.ErrNo)
-		return
-	}
+	err = util.CheckResponse(endpoint, body)
 	return
 }
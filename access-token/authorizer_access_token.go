@@ -0,0 +1,154 @@
+package access_token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/url"
+	"time"
+)
+
+// AuthorizerTokenPath 授权码换取和刷新 authorizer_access_token 的接口路径
+const AuthorizerTokenPath = "/openapi/v1/oauth/token"
+
+// authorizerRefreshExpires 接口未返回 refresh_expires_in 时 authorizer_refresh_token 的缓存时间
+const authorizerRefreshExpires = 30 * 24 * time.Hour
+
+// ErrAuthorizerRefreshTokenMissing 缓存中没有 authorizer_refresh_token 需要小程序重新授权
+var ErrAuthorizerRefreshTokenMissing = errors.New("douyin: authorizer refresh token missing")
+
+// ResAuthorizerToken 换取和刷新 authorizer_access_token 的返回结构体
+type ResAuthorizerToken struct {
+	Errno                  int    `json:"errno"`
+	Message                string `json:"message"`
+	AuthorizerAccessToken  string `json:"authorizer_access_token"`  // 授权小程序接口调用凭据
+	ExpiresIn              int    `json:"expires_in"`               // authorizer_access_token 有效期 单位秒
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"` // 刷新 authorizer_access_token 的凭据
+	RefreshExpiresIn       int    `json:"refresh_expires_in"`       // authorizer_refresh_token 有效期 单位秒
+	AuthorizerAppid        string `json:"authorizer_appid"`         // 授权小程序的 appid
+	AuthorizePermission    []struct {
+		Id       int    `json:"id"`
+		Category string `json:"category"`
+		Desc     string `json:"desc"`
+	} `json:"authorize_permission"` // 授权给第三方平台的权限集
+}
+
+// AuthorizerAccessToken 授权给第三方平台的小程序的 authorizer_access_token 管理类
+type AuthorizerAccessToken struct {
+	Component         *ComponentAccessToken // 第三方平台 token 管理类 多个授权小程序共用
+	AuthorizerAppId   string                // 授权小程序的 appid
+	Cache             cache.Cache           // 缓存组件 默认使用 Component 的缓存
+	LeaseTimeout      time.Duration         // 缓存实现 cache.Locker 时刷新token的租约有效期 默认10秒
+	LeasePollInterval time.Duration         // 等待其他实例刷新token时的轮询间隔 默认100毫秒
+	tokenManager                            // token 的缓存 合并并发获取和分布式租约
+}
+
+// NewAuthorizerAccessToken 实例化授权小程序的token管理类
+func NewAuthorizerAccessToken(component *ComponentAccessToken, authorizerAppId string) AccessToken {
+	if component == nil {
+		panic(any("component is need"))
+	}
+	at := &AuthorizerAccessToken{
+		Component:       component,
+		AuthorizerAppId: authorizerAppId,
+		Cache:           component.Cache,
+	}
+	at.init(fmt.Sprintf("douyin_openapi_authorizer_access_token_%s_%s", component.ComponentAppId, authorizerAppId), at.tokenConfig, at.refreshToken)
+	return at
+}
+
+// tokenConfig 返回 tokenManager 使用的配置 日志使用 Component 的日志
+func (at *AuthorizerAccessToken) tokenConfig() tokenConfig {
+	return tokenConfig{cache: at.Cache, logger: at.Component.Logger, leaseTimeout: at.LeaseTimeout, leasePollInterval: at.LeasePollInterval}
+}
+
+// refreshTokenCacheKey authorizer_refresh_token 的缓存key
+func (at *AuthorizerAccessToken) refreshTokenCacheKey() string {
+	return at.GetCacheKey() + "_refresh_token"
+}
+
+// ExchangeAuthorizationCode 使用小程序授权后回调的授权码换取token并写入缓存
+func (at *AuthorizerAccessToken) ExchangeAuthorizationCode(ctx context.Context, authorizationCode string) (res ResAuthorizerToken, err error) {
+	query := url.Values{}
+	query.Set("authorization_code", authorizationCode)
+	query.Set("grant_type", "app_to_tp_authorization_code")
+	res, err = at.requestToken(ctx, query)
+	if err != nil {
+		return
+	}
+	if res.AuthorizerAppid != "" && res.AuthorizerAppid != at.AuthorizerAppId {
+		err = fmt.Errorf("douyin: authorization code belongs to %s not %s", res.AuthorizerAppid, at.AuthorizerAppId)
+		return
+	}
+	err = at.storeTokens(res)
+	return
+}
+
+// refreshToken 使用缓存的 authorizer_refresh_token 刷新token 刷新后旧的 refresh_token 失效
+func (at *AuthorizerAccessToken) refreshToken(ctx context.Context) (string, error) {
+	refreshToken, ok, err := cache.NewTypedCache[string](at.Cache, cache.StringCodec{}).Get(ctx, at.refreshTokenCacheKey())
+	if err != nil {
+		return "", err
+	}
+	if !ok || refreshToken == "" {
+		return "", ErrAuthorizerRefreshTokenMissing
+	}
+	query := url.Values{}
+	query.Set("authorizer_refresh_token", refreshToken)
+	query.Set("grant_type", "app_to_tp_refresh_token")
+	res, err := at.requestToken(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	// 旧的 refresh_token 已经失效 写入失败也返回新的token
+	if err = at.storeTokens(res); err != nil {
+		util.NewRedactLogger(at.Component.Logger).Log(ctx, util.LevelWarn, "douyin authorizer access token cache write failed",
+			util.Field{Key: "authorizer_appid", Value: at.AuthorizerAppId},
+			util.Field{Key: "error", Value: err},
+		)
+	}
+	return res.AuthorizerAccessToken, nil
+}
+
+// requestToken 携带 component_access_token 请求token接口
+func (at *AuthorizerAccessToken) requestToken(ctx context.Context, query url.Values) (res ResAuthorizerToken, err error) {
+	componentToken, err := at.Component.GetAccessTokenContext(ctx)
+	if err != nil {
+		return
+	}
+	query.Set("component_appid", at.Component.ComponentAppId)
+	query.Set("component_access_token", componentToken)
+	logger := util.NewRedactLogger(at.Component.Logger)
+	err = at.Component.get(ctx, AuthorizerTokenPath, query, &res)
+	if err != nil {
+		logger.Log(ctx, util.LevelError, "douyin authorizer access token request failed",
+			util.Field{Key: "authorizer_appid", Value: at.AuthorizerAppId},
+			util.Field{Key: "grant_type", Value: query.Get("grant_type")},
+			util.Field{Key: "error", Value: err},
+		)
+		return
+	}
+	logger.Log(ctx, util.LevelInfo, "douyin authorizer access token refreshed",
+		util.Field{Key: "authorizer_appid", Value: at.AuthorizerAppId},
+		util.Field{Key: "grant_type", Value: query.Get("grant_type")},
+		util.Field{Key: "expires_in", Value: res.ExpiresIn},
+	)
+	return
+}
+
+// storeTokens 缓存 authorizer_access_token 和 authorizer_refresh_token
+func (at *AuthorizerAccessToken) storeTokens(res ResAuthorizerToken) error {
+	if res.AuthorizerRefreshToken != "" {
+		expires := time.Duration(res.RefreshExpiresIn) * time.Second
+		if expires <= 0 {
+			expires = authorizerRefreshExpires
+		}
+		err := at.Cache.Set(at.refreshTokenCacheKey(), res.AuthorizerRefreshToken, expires)
+		if err != nil {
+			return err
+		}
+	}
+	return storeToken(at.Cache, at.GetCacheKey(), res.AuthorizerAccessToken, time.Duration(res.ExpiresIn-300)*time.Second)
+}
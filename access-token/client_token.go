@@ -0,0 +1,123 @@
+package access_token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"time"
+)
+
+// ClientTokenPath 获取 client_token 的接口路径
+const ClientTokenPath = "/oauth/client_token/"
+
+const (
+	openApiURL        = "https://open.douyin.com"         // 开放平台正式地址
+	openSandboxApiURL = "https://open-sandbox.douyin.com" // 开放平台沙盒地址
+)
+
+// ClientToken 开放平台 open.douyin.com 接口使用的 client_token 管理类
+type ClientToken struct {
+	ClientKey         string             // 应用唯一标识
+	ClientSecret      string             // 应用密钥
+	Cache             cache.Cache        // 缓存组件
+	SandBox           bool               // 是否沙盒地址 默认 false 线上地址
+	HttpClient        util.Doer          // http客户端 为空时使用默认客户端
+	ApiUrl            string             // 自定义获取token的地址 为空时根据 SandBox 选择
+	Interceptors      []util.Interceptor // 获取token时的请求拦截器
+	Logger            util.Logger        // 日志 记录token刷新 为空时不输出日志
+	LeaseTimeout      time.Duration      // 缓存实现 cache.Locker 时刷新token的租约有效期 默认10秒
+	LeasePollInterval time.Duration      // 等待其他实例刷新token时的轮询间隔 默认100毫秒
+	tokenManager                         // 缓存 合并并发获取和分布式租约
+}
+
+// NewClientToken 实例化 client_token 管理类 沙盒和线上的token使用不同的缓存key
+func NewClientToken(clientKey, clientSecret string, cache cache.Cache, isSandbox bool) AccessToken {
+	if cache == nil {
+		panic(any("cache is need"))
+	}
+	cacheKey := fmt.Sprintf("douyin_openapi_client_token_%s", clientKey)
+	if isSandbox {
+		cacheKey = fmt.Sprintf("douyin_openapi_client_token_sandbox_%s", clientKey)
+	}
+	token := &ClientToken{
+		ClientKey:    clientKey,
+		ClientSecret: clientSecret,
+		Cache:        cache,
+		SandBox:      isSandbox,
+	}
+	token.init(cacheKey, token.tokenConfig, token.refreshToken)
+	return token
+}
+
+// tokenConfig 返回 tokenManager 使用的配置
+func (ct *ClientToken) tokenConfig() tokenConfig {
+	return tokenConfig{cache: ct.Cache, logger: ct.Logger, leaseTimeout: ct.LeaseTimeout, leasePollInterval: ct.LeasePollInterval}
+}
+
+// ResClientToken 获取 client_token 的返回结构体
+type ResClientToken struct {
+	Data    ResClientTokenData `json:"data"`
+	Message string             `json:"message"`
+	Extra   struct {
+		Logid string `json:"logid"`
+	} `json:"extra"`
+}
+
+type ResClientTokenData struct {
+	AccessToken string `json:"access_token"`
+	Description string `json:"description"`
+	ErrorCode   int    `json:"error_code"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// refreshToken 调用接口获取 client_token 并写入缓存
+func (ct *ClientToken) refreshToken(ctx context.Context) (string, error) {
+	api := openApiURL + ClientTokenPath
+	if ct.SandBox {
+		api = openSandboxApiURL + ClientTokenPath
+	}
+	if ct.ApiUrl != "" {
+		api = ct.ApiUrl
+	}
+	start := time.Now()
+	logger := util.NewRedactLogger(ct.Logger)
+	invoker := util.ChainInterceptors(util.NewInvoker(ct.HttpClient), ct.Interceptors...)
+	res, err := getClientTokenFromServer(ctx, invoker, api, ct.ClientKey, ct.ClientSecret)
+	if err != nil {
+		logger.Log(ctx, util.LevelError, "douyin client token refresh failed", util.Field{Key: "client_key", Value: ct.ClientKey}, util.Field{Key: "error", Value: err})
+		return "", err
+	}
+	logger.Log(ctx, util.LevelInfo, "douyin client token refreshed",
+		util.Field{Key: "client_key", Value: ct.ClientKey},
+		util.Field{Key: "expires_in", Value: res.Data.ExpiresIn},
+		util.Field{Key: "latency", Value: time.Since(start)},
+	)
+	// 提前5分钟过期 避免使用时刚好失效
+	storeFetchedToken(ctx, ct.Cache, ct.GetCacheKey(), res.Data.AccessToken, time.Duration(res.Data.ExpiresIn-300)*time.Second, ct.Logger)
+	return res.Data.AccessToken, nil
+}
+
+// getClientTokenFromServer 从开放平台获取 client_token
+func getClientTokenFromServer(ctx context.Context, invoker util.Invoker, apiUrl string, clientKey, clientSecret string) (res ResClientToken, err error) {
+	params := map[string]interface{}{
+		"client_key":    clientKey,
+		"client_secret": clientSecret,
+		"grant_type":    "client_credential",
+	}
+	endpoint := util.EndpointName(apiUrl)
+	body, err := invoker(ctx, &util.Request{Endpoint: endpoint, Url: apiUrl, Params: params, Header: http.Header{}})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return
+	}
+	if res.Data.ErrorCode != 0 {
+		err = &util.APIError{Endpoint: endpoint, ErrNo: res.Data.ErrorCode, ErrTips: res.Data.Description, LogID: res.Extra.Logid, RawBody: body}
+	}
+	return
+}
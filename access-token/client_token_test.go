@@ -0,0 +1,43 @@
+package access_token
+
+import (
+	"context"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// 测试获取和缓存 client_token
+func TestClientToken_GetAccessToken(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = w.Write([]byte(`{"data":{"description":"client_key error","error_code":10013},"extra":{"logid":"log_1"},"message":"error"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"access_token":"clt.token","error_code":0,"expires_in":7200},"message":"success"}`))
+	}))
+	defer server.Close()
+	c := cache.NewMemory()
+	token := NewClientToken("client_key", "client_secret", c, true).(*ClientToken)
+	token.ApiUrl = server.URL
+	var apiError *util.APIError
+	if _, err := token.GetAccessToken(); !errors.As(err, &apiError) || apiError.ErrNo != 10013 || apiError.LogID != "log_1" {
+		t.Fatalf("got a error %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if val, err := token.GetAccessToken(); err != nil || val != "clt.token" {
+			t.Fatalf("got a error %v value %s", err, val)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 2 || token.GetCacheKey() != "douyin_openapi_client_token_sandbox_client_key" {
+		t.Fatalf("got calls %d key %s", calls, token.GetCacheKey())
+	}
+	if val, err := token.ForceRefresh(context.Background()); err != nil || val != "clt.token" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("got a error %v calls %d", err, calls)
+	}
+}
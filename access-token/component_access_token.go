@@ -0,0 +1,155 @@
+package access_token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 第三方平台接口地址
+const componentApiURL = "https://open.microapp.bytedance.com"
+
+// ComponentAccessTokenPath 获取 component_access_token 的接口路径
+const ComponentAccessTokenPath = "/openapi/v1/auth/tp/token"
+
+// componentTicketExpires ticket 的缓存时间 平台会定时推送新的ticket
+const componentTicketExpires = 12 * time.Hour
+
+// ErrComponentTicketMissing 还未收到 component_ticket 推送
+var ErrComponentTicketMissing = errors.New("douyin: component ticket missing")
+
+// ComponentAccessToken 第三方平台 component_access_token 管理类
+type ComponentAccessToken struct {
+	ComponentAppId     string             // 第三方平台 appid
+	ComponentAppSecret string             // 第三方平台 appsecret
+	Token              string             // 消息校验 Token
+	EncodingAesKey     string             // 消息加解密 Key
+	Cache              cache.Cache        // 缓存组件 保存 ticket 和 token
+	HttpClient         util.Doer          // http客户端 为空时使用默认客户端
+	BaseApi            string             // 自定义接口地址 为空时使用线上地址
+	Interceptors       []util.Interceptor // 请求拦截器
+	Logger             util.Logger        // 日志 为空时不输出日志
+	LeaseTimeout       time.Duration      // 缓存实现 cache.Locker 时刷新token的租约有效期 默认10秒
+	LeasePollInterval  time.Duration      // 等待其他实例刷新token时的轮询间隔 默认100毫秒
+	tokenManager                          // token 的缓存 合并并发获取和分布式租约
+}
+
+// NewComponentAccessToken 实例化第三方平台 token 管理类
+func NewComponentAccessToken(componentAppId, componentAppSecret, token, encodingAesKey string, cache cache.Cache) *ComponentAccessToken {
+	if cache == nil {
+		panic(any("cache is need"))
+	}
+	ct := &ComponentAccessToken{
+		ComponentAppId:     componentAppId,
+		ComponentAppSecret: componentAppSecret,
+		Token:              token,
+		EncodingAesKey:     encodingAesKey,
+		Cache:              cache,
+	}
+	ct.init(fmt.Sprintf("douyin_openapi_component_access_token_%s", componentAppId), ct.tokenConfig, ct.refreshToken)
+	return ct
+}
+
+// tokenConfig 返回 tokenManager 使用的配置
+func (ct *ComponentAccessToken) tokenConfig() tokenConfig {
+	return tokenConfig{cache: ct.Cache, logger: ct.Logger, leaseTimeout: ct.LeaseTimeout, leasePollInterval: ct.LeasePollInterval}
+}
+
+// ticketCacheKey ticket 的缓存key
+func (ct *ComponentAccessToken) ticketCacheKey() string {
+	return fmt.Sprintf("douyin_openapi_component_ticket_%s", ct.ComponentAppId)
+}
+
+// SetTicket 保存平台推送的 component_ticket
+func (ct *ComponentAccessToken) SetTicket(ticket string) error {
+	if ticket == "" {
+		return ErrComponentTicketMissing
+	}
+	return ct.Cache.Set(ct.ticketCacheKey(), ticket, componentTicketExpires)
+}
+
+// GetTicket 获取保存的 component_ticket
+func (ct *ComponentAccessToken) GetTicket() (string, error) {
+	ticket, ok, err := cache.NewTypedCache[string](ct.Cache, cache.StringCodec{}).Get(context.Background(), ct.ticketCacheKey())
+	if err != nil {
+		return "", err
+	}
+	if !ok || ticket == "" {
+		return "", ErrComponentTicketMissing
+	}
+	return ticket, nil
+}
+
+// componentResponse 第三方平台接口的公共返回字段
+type componentResponse struct {
+	Errno   int    `json:"errno"`
+	Message string `json:"message"`
+}
+
+// ResComponentAccessToken 获取 component_access_token 的返回结构体
+type ResComponentAccessToken struct {
+	Errno                int    `json:"errno"`
+	Message              string `json:"message"`
+	ComponentAccessToken string `json:"component_access_token"`
+	ExpiresIn            int    `json:"expires_in"`
+}
+
+// refreshToken 使用 component_ticket 换取 component_access_token 并写入缓存
+func (ct *ComponentAccessToken) refreshToken(ctx context.Context) (string, error) {
+	ticket, err := ct.GetTicket()
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("component_appid", ct.ComponentAppId)
+	query.Set("component_appsecret", ct.ComponentAppSecret)
+	query.Set("component_ticket", ticket)
+	var res ResComponentAccessToken
+	logger := util.NewRedactLogger(ct.Logger)
+	if err = ct.get(ctx, ComponentAccessTokenPath, query, &res); err != nil {
+		logger.Log(ctx, util.LevelError, "douyin component access token refresh failed", util.Field{Key: "component_appid", Value: ct.ComponentAppId}, util.Field{Key: "error", Value: err})
+		return "", err
+	}
+	logger.Log(ctx, util.LevelInfo, "douyin component access token refreshed",
+		util.Field{Key: "component_appid", Value: ct.ComponentAppId},
+		util.Field{Key: "expires_in", Value: res.ExpiresIn},
+	)
+	storeFetchedToken(ctx, ct.Cache, ct.GetCacheKey(), res.ComponentAccessToken, time.Duration(res.ExpiresIn-300)*time.Second, ct.Logger)
+	return res.ComponentAccessToken, nil
+}
+
+// get 请求第三方平台接口 返回 errno 不为0时返回 *util.APIError
+func (ct *ComponentAccessToken) get(ctx context.Context, path string, query url.Values, response interface{}) error {
+	api := componentApiURL
+	if ct.BaseApi != "" {
+		api = strings.TrimRight(ct.BaseApi, "/")
+	}
+	invoker := util.ChainInterceptors(util.NewInvoker(ct.HttpClient), ct.Interceptors...)
+	body, err := invoker(ctx, &util.Request{Endpoint: path, Method: http.MethodGet, Url: api + path + "?" + query.Encode(), Header: http.Header{}})
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, response); err != nil {
+		return err
+	}
+	var envelope componentResponse
+	if err = json.Unmarshal(body, &envelope); err == nil && envelope.Errno != 0 {
+		return &util.APIError{Endpoint: path, ErrNo: envelope.Errno, ErrTips: envelope.Message, RawBody: body}
+	}
+	return nil
+}
+
+// logError 记录错误日志
+func (ct *ComponentAccessToken) logError(r *http.Request, msg string, err error) {
+	util.NewRedactLogger(ct.Logger).Log(r.Context(), util.LevelError, msg,
+		util.Field{Key: "component_appid", Value: ct.ComponentAppId},
+		util.Field{Key: "error", Value: err},
+	)
+}
@@ -0,0 +1,183 @@
+package access_token
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encryptTicketPush 按平台规则构造加密的 ticket 推送
+func encryptTicketPush(t *testing.T, ct *ComponentAccessToken, message string) []byte {
+	key, err := base64.StdEncoding.DecodeString(ct.EncodingAesKey + "=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := bytes.NewBufferString("0123456789abcdef")
+	_ = binary.Write(plain, binary.BigEndian, uint32(len(message)))
+	plain.WriteString(message)
+	plain.WriteString(ct.ComponentAppId)
+	pad := aes.BlockSize - plain.Len()%aes.BlockSize
+	plain.Write(bytes.Repeat([]byte{byte(pad)}, pad))
+	block, _ := aes.NewCipher(key)
+	cipherText := make([]byte, plain.Len())
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(cipherText, plain.Bytes())
+	push := ComponentTicketPush{TimeStamp: "1700000000", Nonce: "nonce", Encrypt: base64.StdEncoding.EncodeToString(cipherText)}
+	strArr := []string{ct.Token, push.TimeStamp, push.Nonce, push.Encrypt}
+	sort.Strings(strArr)
+	push.MsgSignature = fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(strArr, ""))))
+	body, _ := json.Marshal(push)
+	return body
+}
+
+// 测试接收 ticket 推送并换取 component_access_token
+func TestComponentAccessToken_TicketPush(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != ComponentAccessTokenPath || r.URL.Query().Get("component_ticket") != "ticket_1" {
+			_, _ = w.Write([]byte(`{"errno":40001,"message":"bad ticket"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"component_access_token":"component.token","expires_in":7200}`))
+	}))
+	defer server.Close()
+	ct := NewComponentAccessToken("tp_appid", "tp_secret", "verify_token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", cache.NewMemory())
+	ct.BaseApi = server.URL
+	if _, err := ct.GetAccessToken(); !errors.Is(err, ErrComponentTicketMissing) {
+		t.Fatalf("got a error %v", err)
+	}
+
+	body := encryptTicketPush(t, ct, `{"Ticket":"ticket_1","MsgType":"Ticket","FromUserName":"ByteDance","ToUserName":"tp_appid"}`)
+	rec := httptest.NewRecorder()
+	ct.TicketHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ticket", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "success" {
+		t.Fatalf("got code %d body %s", rec.Code, rec.Body.String())
+	}
+	if ticket, err := ct.GetTicket(); err != nil || ticket != "ticket_1" {
+		t.Fatalf("got a error %v ticket %s", err, ticket)
+	}
+	if val, err := ct.GetAccessToken(); err != nil || val != "component.token" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+
+	// 签名错误的推送不保存
+	tampered := bytes.Replace(body, []byte(`"Nonce":"nonce"`), []byte(`"Nonce":"other"`), 1)
+	rec = httptest.NewRecorder()
+	ct.TicketHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ticket", bytes.NewReader(tampered)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got code %d", rec.Code)
+	}
+}
+
+// 测试授权码换取和刷新 authorizer_access_token
+func TestAuthorizerAccessToken_Refresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case r.URL.Path == ComponentAccessTokenPath:
+			_, _ = w.Write([]byte(`{"component_access_token":"component.token","expires_in":7200}`))
+		case query.Get("component_access_token") != "component.token":
+			_, _ = w.Write([]byte(`{"errno":40004,"message":"bad component token"}`))
+		case query.Get("grant_type") == "app_to_tp_authorization_code" && query.Get("authorization_code") == "code_1":
+			_, _ = w.Write([]byte(`{"authorizer_access_token":"authorizer.token.1","expires_in":7200,"authorizer_refresh_token":"refresh.1","refresh_expires_in":2592000,"authorizer_appid":"tt_app"}`))
+		case query.Get("grant_type") == "app_to_tp_refresh_token" && query.Get("authorizer_refresh_token") == "refresh.1":
+			_, _ = w.Write([]byte(`{"authorizer_access_token":"authorizer.token.2","expires_in":7200,"authorizer_refresh_token":"refresh.2","refresh_expires_in":2592000,"authorizer_appid":"tt_app"}`))
+		default:
+			_, _ = w.Write([]byte(`{"errno":40020,"message":"bad refresh token"}`))
+		}
+	}))
+	defer server.Close()
+	ct := NewComponentAccessToken("tp_appid", "tp_secret", "verify_token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", cache.NewMemory())
+	ct.BaseApi = server.URL
+	if err := ct.SetTicket("ticket_1"); err != nil {
+		t.Fatal(err)
+	}
+	token := NewAuthorizerAccessToken(ct, "tt_app").(*AuthorizerAccessToken)
+	if _, err := token.GetAccessToken(); !errors.Is(err, ErrAuthorizerRefreshTokenMissing) {
+		t.Fatalf("got a error %v", err)
+	}
+	if res, err := token.ExchangeAuthorizationCode(context.Background(), "code_1"); err != nil || res.AuthorizerRefreshToken != "refresh.1" {
+		t.Fatalf("got a error %v res %+v", err, res)
+	}
+	if val, err := token.GetAccessToken(); err != nil || val != "authorizer.token.1" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	if val, err := token.ForceRefresh(context.Background()); err != nil || val != "authorizer.token.2" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	// 旧的 refresh_token 已被替换
+	if val, _ := ct.Cache.Get(token.refreshTokenCacheKey()).(string); val != "refresh.2" {
+		t.Fatalf("got refresh token %s", val)
+	}
+}
+
+// 测试获取 component_access_token 失败时错误和日志中不包含 secret 和 ticket
+func TestComponentAccessToken_ErrorRedacted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	for _, baseApi := range []string{server.URL, closed.URL} {
+		var output bytes.Buffer
+		ct := NewComponentAccessToken("tp_appid", "TOP_SECRET_VALUE", "verify_token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", cache.NewMemory())
+		ct.BaseApi = baseApi
+		ct.Logger = util.NewStdLogger(log.New(&output, "", 0), util.LevelDebug)
+		_ = ct.SetTicket("TICKET_VALUE")
+		_, err := ct.GetAccessToken()
+		if err == nil {
+			t.Fatalf("token request should fail")
+		}
+		assertNoSecrets(t, err.Error()+output.String(), "TOP_SECRET_VALUE", "TICKET_VALUE")
+	}
+}
+
+// assertNoSecrets 断言文本中不包含敏感值
+func assertNoSecrets(t *testing.T, text string, secrets ...string) {
+	t.Helper()
+	for _, secret := range secrets {
+		if strings.Contains(text, secret) {
+			t.Fatalf("secret %s leaked: %s", secret, text)
+		}
+	}
+}
+
+// 测试换取和刷新 authorizer_access_token 失败时错误和日志中不包含授权码和token
+func TestAuthorizerAccessToken_ErrorRedacted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == ComponentAccessTokenPath {
+			_, _ = w.Write([]byte(`{"component_access_token":"COMPONENT_TOKEN_VALUE","expires_in":7200}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	var output bytes.Buffer
+	ct := NewComponentAccessToken("tp_appid", "TOP_SECRET_VALUE", "verify_token", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG", cache.NewMemory())
+	ct.BaseApi = server.URL
+	ct.Logger = util.NewStdLogger(log.New(&output, "", 0), util.LevelDebug)
+	_ = ct.SetTicket("TICKET_VALUE")
+	token := NewAuthorizerAccessToken(ct, "tt_app").(*AuthorizerAccessToken)
+	_, exchangeErr := token.ExchangeAuthorizationCode(context.Background(), "CODE_VALUE")
+	_ = ct.Cache.Set(token.refreshTokenCacheKey(), "REFRESH_TOKEN_VALUE", time.Hour)
+	_, refreshErr := token.GetAccessToken()
+	if exchangeErr == nil || refreshErr == nil {
+		t.Fatalf("token request should fail %v %v", exchangeErr, refreshErr)
+	}
+	assertNoSecrets(t, exchangeErr.Error()+refreshErr.Error()+output.String(), "CODE_VALUE", "REFRESH_TOKEN_VALUE", "COMPONENT_TOKEN_VALUE", "TOP_SECRET_VALUE", "TICKET_VALUE")
+}
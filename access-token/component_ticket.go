@@ -0,0 +1,116 @@
+package access_token
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ComponentTicketPush 第三方平台推送的加密消息
+type ComponentTicketPush struct {
+	TimeStamp    string `json:"TimeStamp"`
+	Nonce        string `json:"Nonce"`
+	Encrypt      string `json:"Encrypt"`
+	MsgSignature string `json:"MsgSignature"`
+}
+
+// ComponentTicketMessage 解密后的 component_ticket 消息
+type ComponentTicketMessage struct {
+	Ticket       string `json:"Ticket"`
+	CreateTime   int64  `json:"CreateTime"`
+	MsgType      string `json:"MsgType"`
+	FromUserName string `json:"FromUserName"`
+	ToUserName   string `json:"ToUserName"`
+}
+
+// ParseTicketPush 校验推送消息的签名并解密出 component_ticket 消息
+func (ct *ComponentAccessToken) ParseTicketPush(body []byte) (message ComponentTicketMessage, err error) {
+	var push ComponentTicketPush
+	err = json.Unmarshal(body, &push)
+	if err != nil {
+		return
+	}
+	// 签名为 Token TimeStamp Nonce Encrypt 排序拼接后的 sha1
+	strArr := []string{ct.Token, push.TimeStamp, push.Nonce, push.Encrypt}
+	sort.Strings(strArr)
+	sign := fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(strArr, ""))))
+	if sign != push.MsgSignature {
+		err = fmt.Errorf("component ticket 验签失败 newSign:%s oldSign:%s", sign, push.MsgSignature)
+		return
+	}
+	plain, err := ct.decrypt(push.Encrypt)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(plain, &message)
+	return
+}
+
+// decrypt 解密推送消息 明文格式为 16字节随机串 + 4字节消息长度 + 消息 + 第三方平台appid
+func (ct *ComponentAccessToken) decrypt(encrypt string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(ct.EncodingAesKey + "=")
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return nil, errors.New("component ticket 密文长度错误")
+	}
+	plain := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, key[:aes.BlockSize]).CryptBlocks(plain, cipherText)
+	// 去除 PKCS#7 填充
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > 32 || pad > len(plain) || !bytes.Equal(bytes.Repeat([]byte{byte(pad)}, pad), plain[len(plain)-pad:]) {
+		return nil, errors.New("component ticket 填充错误")
+	}
+	plain = plain[:len(plain)-pad]
+	if len(plain) < 20 {
+		return nil, errors.New("component ticket 明文长度错误")
+	}
+	msgLen := int(binary.BigEndian.Uint32(plain[16:20]))
+	if msgLen > len(plain)-20 {
+		return nil, errors.New("component ticket 消息长度错误")
+	}
+	if appId := string(plain[20+msgLen:]); appId != ct.ComponentAppId {
+		return nil, fmt.Errorf("component ticket appid 不匹配: %s", appId)
+	}
+	return plain[20 : 20+msgLen], nil
+}
+
+// TicketHandler 接收 component_ticket 推送的 http 处理器 校验并保存ticket后返回 success
+func (ct *ComponentAccessToken) TicketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		message, err := ct.ParseTicketPush(body)
+		if err == nil {
+			err = ct.SetTicket(message.Ticket)
+		}
+		if err != nil {
+			ct.logError(r, "douyin component ticket push failed", err)
+			http.Error(w, "fail", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("success"))
+	})
+}
@@ -0,0 +1,94 @@
+package access_token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"time"
+)
+
+const (
+	defaultLeaseTimeout      = 10 * time.Second       // 默认的租约有效期
+	defaultLeasePollInterval = 100 * time.Millisecond // 默认等待其他实例刷新时的轮询间隔
+)
+
+// tokenLease 跨进程的token刷新租约
+// 缓存实现了 cache.Locker 时 同一时间只有获得租约的实例调用接口刷新token 其他实例轮询共享缓存中的新token
+type tokenLease struct {
+	cache        cache.Cache
+	key          string        // 租约的缓存key
+	timeout      time.Duration // 租约有效期 持有租约的实例异常退出后 其他实例最多等待该时间
+	pollInterval time.Duration // 轮询间隔
+}
+
+// newTokenLease 实例化一个租约 tokenKey 为token的缓存key
+func newTokenLease(c cache.Cache, tokenKey string, timeout, pollInterval time.Duration) tokenLease {
+	if timeout <= 0 {
+		timeout = defaultLeaseTimeout
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultLeasePollInterval
+	}
+	return tokenLease{cache: c, key: tokenKey + "_lease", timeout: timeout, pollInterval: pollInterval}
+}
+
+// load 获取token cached 返回共享缓存中可用的token 没有可用的token时在租约保护下调用 fetch 刷新
+func (l tokenLease) load(ctx context.Context, cached func() (string, bool, error), fetch func(ctx context.Context) (string, error)) (string, error) {
+	locker, ok := l.cache.(cache.Locker)
+	for {
+		if token, ok, err := cached(); err != nil || ok {
+			return token, err
+		}
+		if !ok {
+			return fetch(ctx)
+		}
+		owner := leaseOwner()
+		acquired, err := locker.SetNX(l.key, owner, l.timeout)
+		if err != nil {
+			// 共享缓存不可用时不等待租约 直接刷新
+			return fetch(ctx)
+		}
+		if acquired {
+			return l.fetchWithLease(ctx, owner, cached, fetch)
+		}
+		// 其他实例正在刷新 等待共享缓存中的新token 租约过期后重新竞争
+		timer := time.NewTimer(l.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchWithLease 持有租约时刷新token 结束后释放租约
+func (l tokenLease) fetchWithLease(ctx context.Context, owner string, cached func() (string, bool, error), fetch func(ctx context.Context) (string, error)) (string, error) {
+	defer l.release(owner)
+	// 获得租约前其他实例可能刚刚完成刷新
+	if token, ok, err := cached(); err != nil || ok {
+		return token, err
+	}
+	return fetch(ctx)
+}
+
+// release 释放自己持有的租约 缓存实现了 cache.CompareDeleter 时原子地比较和删除
+func (l tokenLease) release(owner string) {
+	if deleter, ok := l.cache.(cache.CompareDeleter); ok {
+		_, _ = deleter.CompareAndDelete(l.key, owner)
+		return
+	}
+	if val, ok, _ := cache.NewTypedCache[string](l.cache, cache.StringCodec{}).Get(context.Background(), l.key); ok && val == owner {
+		_ = l.cache.Delete(l.key)
+	}
+}
+
+// leaseOwner 生成租约持有者的随机标识
+func leaseOwner() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,64 @@
+package access_token
+
+import (
+	"context"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试其他实例持有租约时等待共享缓存中的token
+func TestDefaultAccessToken_Lease(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"own_token","expires_in":7200}}`))
+	}))
+	defer server.Close()
+	shared := cache.NewMemory()
+	token := NewDefaultAccessToken("tt_lease", "secret", shared, false).(*DefaultAccessToken)
+	token.ApiUrl = server.URL
+	token.LeasePollInterval = 5 * time.Millisecond
+
+	// 模拟其他实例持有租约并写入token
+	lease := newTokenLease(shared, token.GetCacheKey(), time.Minute, 0)
+	if ok, err := shared.(cache.Locker).SetNX(lease.key, "other", time.Minute); !ok || err != nil {
+		t.Fatalf("acquire lease failed %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := token.GetAccessTokenContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got a error %v", err)
+	}
+	_ = shared.Set(token.GetCacheKey(), "shared_token", time.Minute)
+	val, err := token.GetAccessToken()
+	if err != nil || val != "shared_token" || atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("got a error %v value %s calls %d", err, val, calls)
+	}
+
+	// 租约释放后由本实例刷新
+	_ = shared.Delete(token.GetCacheKey())
+	_ = shared.Delete(lease.key)
+	val, err = token.GetAccessToken()
+	if err != nil || val != "own_token" || atomic.LoadInt32(&calls) != 1 || shared.IsExist(lease.key) {
+		t.Fatalf("got a error %v value %s calls %d", err, val, calls)
+	}
+}
+
+// 测试租约过期被其他实例取得后 原持有者不会误删其他实例的租约
+func TestTokenLease_ReleaseOwner(t *testing.T) {
+	shared := cache.NewMemory()
+	lease := newTokenLease(shared, "douyin_openapi_access_token_app", time.Minute, 0)
+	_ = shared.Set(lease.key, "other", time.Minute)
+	lease.release("expired_owner")
+	if shared.Get(lease.key) != "other" {
+		t.Fatalf("release should not delete the lease of other owner")
+	}
+	lease.release("other")
+	if shared.IsExist(lease.key) {
+		t.Fatalf("release should delete own lease")
+	}
+}
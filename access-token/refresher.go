@@ -0,0 +1,115 @@
+package access_token
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval 两次刷新的最小间隔 防止token有效期过短时频繁刷新
+const minRefreshInterval = time.Second
+
+// Refresher 在token过期前主动刷新 避免过期后的第一个请求等待获取token
+// 刷新期间以及刷新失败时 缓存中未过期的旧token会继续使用
+type Refresher struct {
+	Token         *DefaultAccessToken // 需要刷新的token
+	Ahead         time.Duration       // 在缓存过期前多久刷新 默认5分钟
+	Jitter        time.Duration       // 随机提前的最大时间 避免多个实例同时刷新 默认30秒
+	RetryInterval time.Duration       // 刷新失败后的重试间隔 默认10秒
+	OnError       func(err error)     // 刷新失败的回调
+	lock          sync.Mutex
+	cancel        context.CancelFunc
+	done          chan struct{}
+}
+
+// NewRefresher 实例化一个token刷新器 需要调用 Start 开始刷新
+func NewRefresher(token *DefaultAccessToken, onError func(err error)) *Refresher {
+	return &Refresher{
+		Token:         token,
+		Ahead:         5 * time.Minute,
+		Jitter:        30 * time.Second,
+		RetryInterval: 10 * time.Second,
+		OnError:       onError,
+	}
+}
+
+// Start 启动后台刷新 重复调用无效
+func (r *Refresher) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+// Stop 停止后台刷新 并等待正在进行的刷新结束
+func (r *Refresher) Stop() {
+	r.lock.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.lock.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// run 刷新循环
+func (r *Refresher) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	wait := r.nextRefresh()
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := r.refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if r.OnError != nil {
+				r.OnError(err)
+			}
+			wait = r.RetryInterval
+			if wait <= 0 {
+				wait = 10 * time.Second
+			}
+			continue
+		}
+		wait = r.nextRefresh()
+	}
+}
+
+// refresh 刷新一次token
+func (r *Refresher) refresh(ctx context.Context) error {
+	// 剩余有效期超过提前量时说明其他实例已经刷新过 直接使用共享缓存中的token
+	_, err := r.Token.flight.Do(ctx, r.Token.GetCacheKey(), func(ctx context.Context) (string, error) {
+		return r.Token.loadToken(ctx, r.Ahead+r.Jitter)
+	})
+	return err
+}
+
+// nextRefresh 计算距离下次刷新的时间 未获取过token时立即刷新
+func (r *Refresher) nextRefresh() time.Duration {
+	expiresAt := tokenExpiresAt(r.Token.Cache, r.Token.GetCacheKey())
+	if expiresAt.IsZero() {
+		return 0
+	}
+	wait := time.Until(expiresAt) - r.Ahead
+	if r.Jitter > 0 {
+		wait -= time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+	if wait < minRefreshInterval {
+		wait = minRefreshInterval
+	}
+	return wait
+}
@@ -0,0 +1,179 @@
+package access_token
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	TokenSecretHeader = "X-Douyin-Token-Secret" // 访问token中心的共享密钥请求头
+	StaleTokenHeader  = "X-Douyin-Stale-Token"  // 已失效的token 中心仅在缓存的token与其相同时刷新
+)
+
+const (
+	remoteTokenMargin       = time.Minute      // 本地缓存token时提前过期的时间 避免使用中心即将刷新的token
+	remoteTokenShortExpires = 10 * time.Second // 有效期未知或不足 remoteTokenMargin 时本地缓存的时间 避免每次都请求中心
+)
+
+// ResRemoteToken token中心返回的结构体
+type ResRemoteToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"` // 剩余有效期 单位秒 0 表示未知
+	Error       string `json:"error,omitempty"`
+}
+
+// RemoteAccessToken 从内部的token中心获取token 由中心负责刷新 多个服务共用同一个token
+type RemoteAccessToken struct {
+	Url          string             // token中心的地址
+	Secret       string             // 访问token中心的共享密钥
+	Cache        cache.Cache        // 本地缓存组件 缓存中心返回的token
+	HttpClient   util.Doer          // http客户端 为空时使用默认客户端
+	Interceptors []util.Interceptor // 请求token中心时的请求拦截器
+	Logger       util.Logger        // 日志 为空时不输出日志
+	tokenManager                    // 本地缓存和合并并发获取 由中心负责刷新 不使用分布式租约
+	mu           sync.Mutex
+	staleToken   string // 等待中心刷新的已失效token
+}
+
+// NewRemoteAccessToken 实例化从token中心获取token的管理类
+func NewRemoteAccessToken(url, secret string, cache cache.Cache) AccessToken {
+	if cache == nil {
+		panic(any("cache is need"))
+	}
+	rt := &RemoteAccessToken{
+		Url:    url,
+		Secret: secret,
+		Cache:  cache,
+	}
+	rt.init(fmt.Sprintf("douyin_openapi_remote_access_token_%x", md5.Sum([]byte(url))), rt.tokenConfig, rt.fetchToken)
+	rt.withoutLease = true
+	return rt
+}
+
+// tokenConfig 返回 tokenManager 使用的配置
+func (rt *RemoteAccessToken) tokenConfig() tokenConfig {
+	return tokenConfig{cache: rt.Cache, logger: rt.Logger}
+}
+
+// Invalidate 使本地缓存的token失效 下次获取时通知token中心刷新该token
+func (rt *RemoteAccessToken) Invalidate(staleToken string) error {
+	return rt.tokenManager.Invalidate(rt.markStale(staleToken))
+}
+
+// ForceRefresh 使当前的token失效并从token中心重新获取
+func (rt *RemoteAccessToken) ForceRefresh(ctx context.Context) (string, error) {
+	rt.markStale("")
+	return rt.tokenManager.ForceRefresh(ctx)
+}
+
+// markStale 记录需要通知token中心刷新的token staleToken 为空时使用本地缓存的token
+func (rt *RemoteAccessToken) markStale(staleToken string) string {
+	if staleToken == "" {
+		staleToken, _, _ = cachedToken(context.Background(), rt.Cache, rt.GetCacheKey(), 0, rt.Logger)
+	}
+	if staleToken != "" {
+		rt.mu.Lock()
+		rt.staleToken = staleToken
+		rt.mu.Unlock()
+	}
+	return staleToken
+}
+
+// fetchToken 请求token中心获取token并写入本地缓存
+func (rt *RemoteAccessToken) fetchToken(ctx context.Context) (string, error) {
+	header := http.Header{}
+	header.Set(TokenSecretHeader, rt.Secret)
+	rt.mu.Lock()
+	staleToken := rt.staleToken
+	rt.mu.Unlock()
+	if staleToken != "" {
+		header.Set(StaleTokenHeader, staleToken)
+	}
+	invoker := util.ChainInterceptors(util.NewInvoker(rt.HttpClient), rt.Interceptors...)
+	body, err := invoker(ctx, &util.Request{Endpoint: util.EndpointName(rt.Url), Method: http.MethodGet, Url: rt.Url, Header: header})
+	if err != nil {
+		return "", err
+	}
+	var res ResRemoteToken
+	if err = json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	if res.AccessToken == "" {
+		return "", fmt.Errorf("douyin: token broker returned no token: %s", res.Error)
+	}
+	rt.mu.Lock()
+	if rt.staleToken == staleToken {
+		rt.staleToken = ""
+	}
+	rt.mu.Unlock()
+	remaining := time.Duration(res.ExpiresIn) * time.Second
+	expires := remaining - remoteTokenMargin
+	if expires <= 0 {
+		// 短暂缓存 已知剩余有效期时不超过其一半
+		expires = remoteTokenShortExpires
+		if remaining > 0 && remaining/2 < expires {
+			expires = remaining / 2
+		}
+	}
+	storeFetchedToken(ctx, rt.Cache, rt.GetCacheKey(), res.AccessToken, expires, rt.Logger)
+	return res.AccessToken, nil
+}
+
+// TokenHandler 对内提供token的 http 处理器 请求需携带共享密钥请求头
+type TokenHandler struct {
+	Token  *DefaultAccessToken // 由token中心负责刷新的token
+	Secret string              // 共享密钥
+}
+
+// NewTokenHandler 实例化对内提供token的 http 处理器
+func NewTokenHandler(token *DefaultAccessToken, secret string) *TokenHandler {
+	if token == nil {
+		panic(any("token is need"))
+	}
+	if secret == "" {
+		panic(any("secret is need"))
+	}
+	return &TokenHandler{Token: token, Secret: secret}
+}
+
+// ServeHTTP 返回当前的token和剩余有效期 携带 StaleTokenHeader 时先使该token失效
+func (th *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenSecretHeader)), []byte(th.Secret)) != 1 {
+		th.write(w, http.StatusUnauthorized, ResRemoteToken{Error: "unauthorized"})
+		return
+	}
+	ctx := r.Context()
+	if staleToken := r.Header.Get(StaleTokenHeader); staleToken != "" {
+		if err := th.Token.Invalidate(staleToken); err != nil {
+			th.write(w, http.StatusBadGateway, ResRemoteToken{Error: err.Error()})
+			return
+		}
+	}
+	token, err := th.Token.GetAccessTokenContext(ctx)
+	if err != nil {
+		util.NewRedactLogger(th.Token.Logger).Log(ctx, util.LevelError, "douyin token broker failed", util.Field{Key: "app_id", Value: th.Token.AppId}, util.Field{Key: "error", Value: err})
+		th.write(w, http.StatusBadGateway, ResRemoteToken{Error: err.Error()})
+		return
+	}
+	res := ResRemoteToken{AccessToken: token}
+	if expiresAt := tokenExpiresAt(th.Token.Cache, th.Token.GetCacheKey()); !expiresAt.IsZero() {
+		res.ExpiresIn = int(time.Until(expiresAt) / time.Second)
+	}
+	th.write(w, http.StatusOK, res)
+}
+
+// write 输出json结果
+func (th *TokenHandler) write(w http.ResponseWriter, status int, res ResRemoteToken) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,79 @@
+package access_token
+
+import (
+	"context"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// 测试通过token中心共享token
+func TestRemoteAccessToken_Broker(t *testing.T) {
+	var calls int32
+	douyin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"token.1","expires_in":7200}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"token.2","expires_in":7200}}`))
+	}))
+	defer douyin.Close()
+	token := NewDefaultAccessToken("app_id", "app_secret", cache.NewMemory(), false).(*DefaultAccessToken)
+	token.ApiUrl = douyin.URL
+	broker := httptest.NewServer(NewTokenHandler(token, "broker_secret"))
+	defer broker.Close()
+
+	unauthorized := NewRemoteAccessToken(broker.URL, "wrong", cache.NewMemory())
+	var statusError *util.HttpStatusError
+	if _, err := unauthorized.GetAccessToken(); !errors.As(err, &statusError) || statusError.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got a error %v", err)
+	}
+
+	remote := NewRemoteAccessToken(broker.URL, "broker_secret", cache.NewMemory())
+	for i := 0; i < 3; i++ {
+		if val, err := remote.GetAccessToken(); err != nil || val != "token.1" {
+			t.Fatalf("got a error %v value %s", err, val)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("got calls %d", calls)
+	}
+	// 其他服务上报token失效后由中心刷新
+	if err := remote.Invalidate("token.1"); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := remote.GetAccessToken(); err != nil || val != "token.2" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	// 已刷新过的旧token不会再次触发刷新
+	other := NewRemoteAccessToken(broker.URL, "broker_secret", cache.NewMemory())
+	_ = other.Invalidate("token.1")
+	if val, err := other.ForceRefresh(context.Background()); err != nil || val != "token.2" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("got a error %v value %s calls %d", err, val, calls)
+	}
+}
+
+// 测试中心返回的有效期未知或不足时短暂缓存token
+func TestRemoteAccessToken_ShortExpires(t *testing.T) {
+	for _, expiresIn := range []string{"0", "30"} {
+		var calls int32
+		broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			_, _ = w.Write([]byte(`{"access_token":"token.1","expires_in":` + expiresIn + `}`))
+		}))
+		remote := NewRemoteAccessToken(broker.URL, "broker_secret", cache.NewMemory())
+		for i := 0; i < 3; i++ {
+			if val, err := remote.GetAccessToken(); err != nil || val != "token.1" {
+				t.Fatalf("got a error %v value %s", err, val)
+			}
+		}
+		broker.Close()
+		if atomic.LoadInt32(&calls) != 1 {
+			t.Fatalf("expires_in %s got calls %d", expiresIn, calls)
+		}
+	}
+}
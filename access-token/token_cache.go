@@ -0,0 +1,111 @@
+package access_token
+
+import (
+	"context"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"time"
+)
+
+// expiresAtCacheKey 缓存token过期时间的key 共享缓存的实例据此判断是否需要刷新
+func expiresAtCacheKey(key string) string {
+	return key + "_expires_at"
+}
+
+const (
+	cacheReadAttempts = 3                     // 缓存读取失败时的最大尝试次数
+	cacheReadBackoff  = 50 * time.Millisecond // 缓存读取失败后首次重试的等待时间 之后每次翻倍
+)
+
+// cachedToken 获取缓存中剩余有效期超过 minRemaining 的token 值不是字符串时返回错误
+// 缓存读取失败时退避重试 仍然失败时返回错误 不视为未命中 避免缓存不可用时每次都重新获取token
+func cachedToken(ctx context.Context, c cache.Cache, key string, minRemaining time.Duration, logger util.Logger) (string, bool, error) {
+	val, ok, err := lookupToken(ctx, c, key, logger)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	token, err := cache.StringCodec{}.Decode(val)
+	if err != nil {
+		return "", false, err
+	}
+	if minRemaining > 0 && time.Until(tokenExpiresAt(c, key)) <= minRemaining {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// lookupToken 读取缓存 失败时记录日志并退避重试
+func lookupToken(ctx context.Context, c cache.Cache, key string, logger util.Logger) (val interface{}, ok bool, err error) {
+	backoff := cacheReadBackoff
+	for attempt := 1; ; attempt++ {
+		if val, ok, err = cache.Lookup(ctx, c, key); err == nil {
+			return
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, false, ctxErr
+		}
+		util.NewRedactLogger(logger).Log(ctx, util.LevelWarn, "douyin access token cache read failed",
+			util.Field{Key: "key", Value: key},
+			util.Field{Key: "attempt", Value: attempt},
+			util.Field{Key: "error", Value: err},
+		)
+		if attempt >= cacheReadAttempts {
+			return nil, false, fmt.Errorf("douyin: read token cache %s: %w", key, err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// storeFetchedToken 将刚获取的token写入缓存 写入失败时只记录日志 token 仍然可用 不应丢弃后重新获取
+func storeFetchedToken(ctx context.Context, c cache.Cache, key, token string, expires time.Duration, logger util.Logger) {
+	if err := storeToken(c, key, token, expires); err != nil {
+		util.NewRedactLogger(logger).Log(ctx, util.LevelWarn, "douyin access token cache write failed", util.Field{Key: "key", Value: key}, util.Field{Key: "error", Value: err})
+	}
+}
+
+// storeToken 将token和过期时间写入缓存
+func storeToken(c cache.Cache, key, token string, expires time.Duration) error {
+	if err := c.Set(key, token, expires); err != nil {
+		return err
+	}
+	return c.Set(expiresAtCacheKey(key), time.Now().Add(expires).Unix(), expires)
+}
+
+// tokenExpiresAt 获取缓存的token的过期时间 未知时返回零值
+func tokenExpiresAt(c cache.Cache, key string) time.Time {
+	var unix int64
+	switch val := c.Get(expiresAtCacheKey(key)).(type) {
+	case int64:
+		unix = val
+	case int:
+		unix = int64(val)
+	case float64:
+		unix = int64(val)
+	}
+	if unix <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// invalidateToken 删除缓存的token staleToken 不为空时仅在缓存的token与其相同时删除 避免删除其他实例刚刷新的token
+func invalidateToken(c cache.Cache, key, staleToken string) error {
+	if staleToken != "" {
+		token, ok, err := cache.NewTypedCache[string](c, cache.StringCodec{}).Get(context.Background(), key)
+		if err == nil && ok && token != staleToken {
+			return nil
+		}
+	}
+	if err := c.Delete(expiresAtCacheKey(key)); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
@@ -0,0 +1,115 @@
+package access_token
+
+import (
+	"context"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试缓存返回非字符串的token时不会 panic
+func TestDefaultAccessToken_NonStringCache(t *testing.T) {
+	c := cache.NewMemory()
+	token := NewDefaultAccessToken("app_id", "app_secret", c, false)
+	_ = c.Set(token.GetCacheKey(), []byte("bytes.token"), time.Hour)
+	if val, err := token.GetAccessToken(); err != nil || val != "bytes.token" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	_ = c.Set(token.GetCacheKey(), 1234, time.Hour)
+	if _, err := token.GetAccessTokenContext(context.Background()); err == nil {
+		t.Fatalf("non-string token should return error")
+	}
+	_ = c.Set(token.GetCacheKey(), []byte("bytes.token"), time.Hour)
+	if err := token.Invalidate("other.token"); err != nil || !c.IsExist(token.GetCacheKey()) {
+		t.Fatalf("invalidate should keep a token that is not stale")
+	}
+	if err := token.Invalidate("bytes.token"); err != nil || c.IsExist(token.GetCacheKey()) {
+		t.Fatalf("invalidate should delete the stale token")
+	}
+}
+
+// readFailingCache 读取总是失败的共享缓存
+type readFailingCache struct {
+	cache.Cache
+	reads int32
+}
+
+func (c *readFailingCache) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	atomic.AddInt32(&c.reads, 1)
+	return nil, false, errors.New("connection refused")
+}
+
+// writeFailingCache 写入总是失败的共享缓存
+type writeFailingCache struct {
+	cache.Cache
+}
+
+func (writeFailingCache) Set(key string, val interface{}, timeout time.Duration) error {
+	return errors.New("connection refused")
+}
+
+// 测试缓存读取失败时退避重试后返回错误 不重新获取token
+func TestDefaultAccessToken_CacheReadError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"fresh_token","expires_in":7200}}`))
+	}))
+	defer server.Close()
+	var logs int32
+	failing := &readFailingCache{Cache: cache.NewMemory()}
+	token := NewDefaultAccessToken("app_id", "app_secret", failing, false).(*DefaultAccessToken)
+	token.ApiUrl = server.URL
+	token.Logger = loggerFunc(func(level util.Level, msg string) {
+		if level == util.LevelWarn {
+			atomic.AddInt32(&logs, 1)
+		}
+	})
+	if _, err := token.GetAccessToken(); err == nil || atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("got a error %v calls %d", err, calls)
+	}
+	if reads, logs := atomic.LoadInt32(&failing.reads), atomic.LoadInt32(&logs); reads != cacheReadAttempts || logs != cacheReadAttempts {
+		t.Fatalf("got reads %d logs %d", reads, logs)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := token.GetAccessTokenContext(ctx); err != context.Canceled {
+		t.Fatalf("got a error %v", err)
+	}
+}
+
+// 测试获取token后写入缓存失败时仍然返回token
+func TestDefaultAccessToken_CacheWriteError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"fresh_token","expires_in":7200}}`))
+	}))
+	defer server.Close()
+	var logs int32
+	token := NewDefaultAccessToken("app_id", "app_secret", writeFailingCache{cache.NewMemory()}, false).(*DefaultAccessToken)
+	token.ApiUrl = server.URL
+	token.Logger = loggerFunc(func(level util.Level, msg string) {
+		if level == util.LevelWarn {
+			atomic.AddInt32(&logs, 1)
+		}
+	})
+	if val, err := token.GetAccessToken(); err != nil || val != "fresh_token" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("got a error %v value %s calls %d", err, val, calls)
+	}
+	if atomic.LoadInt32(&logs) != 1 {
+		t.Fatalf("cache write error should be logged")
+	}
+}
+
+// loggerFunc 用函数实现 util.Logger
+type loggerFunc func(level util.Level, msg string)
+
+func (f loggerFunc) Log(ctx context.Context, level util.Level, msg string, fields ...util.Field) {
+	f(level, msg)
+}
@@ -0,0 +1,90 @@
+package access_token
+
+import (
+	"context"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"time"
+)
+
+// tokenConfig token管理类当前的缓存 日志和租约配置
+type tokenConfig struct {
+	cache             cache.Cache
+	logger            util.Logger
+	leaseTimeout      time.Duration
+	leasePollInterval time.Duration
+}
+
+// tokenManager 各个token管理类共用的部分 负责读取缓存 合并本实例的并发获取和分布式租约
+// 管理类嵌入后只需提供自己的配置和获取token的方法
+type tokenManager struct {
+	cacheKey     string                                    // 缓存的key
+	flight       util.FlightGroup                          // 合并本实例的并发获取
+	config       func() tokenConfig                        // 读取管理类的配置 实例化后修改的字段也会生效
+	refresh      func(ctx context.Context) (string, error) // 调用接口获取token并写入缓存
+	withoutLease bool                                      // 不使用分布式租约 直接调用 refresh
+}
+
+// init 初始化 由管理类的构造函数调用
+func (m *tokenManager) init(cacheKey string, config func() tokenConfig, refresh func(ctx context.Context) (string, error)) {
+	m.cacheKey = cacheKey
+	m.config = config
+	m.refresh = refresh
+}
+
+// GetCacheKey 获取缓存key
+func (m *tokenManager) GetCacheKey() string {
+	return m.cacheKey
+}
+
+// SetCacheKey 设置缓存key
+func (m *tokenManager) SetCacheKey(key string) {
+	m.cacheKey = key
+}
+
+// GetAccessToken 获取token
+func (m *tokenManager) GetAccessToken() (string, error) {
+	return m.GetAccessTokenContext(context.Background())
+}
+
+// GetAccessTokenContext 获取token 支持传入context
+func (m *tokenManager) GetAccessTokenContext(ctx context.Context) (string, error) {
+	// 先尝试从缓存中获取如果不存在就调用接口获取
+	config := m.config()
+	if token, ok, err := cachedToken(ctx, config.cache, m.GetCacheKey(), 0, config.logger); err != nil || ok {
+		return token, err
+	}
+
+	// 同一个token的并发请求合并为一次获取 不同token互不影响
+	return m.flight.Do(ctx, m.GetCacheKey(), func(ctx context.Context) (string, error) {
+		return m.loadToken(ctx, 0)
+	})
+}
+
+// Invalidate 使缓存的token失效 staleToken 不为空时仅在缓存的token与其相同时失效 避免删除其他实例刚刷新的token
+func (m *tokenManager) Invalidate(staleToken string) error {
+	return invalidateToken(m.config().cache, m.GetCacheKey(), staleToken)
+}
+
+// ForceRefresh 强制重新获取token 并发调用只会获取一次
+func (m *tokenManager) ForceRefresh(ctx context.Context) (string, error) {
+	return m.flight.Do(ctx, m.GetCacheKey(), func(ctx context.Context) (string, error) {
+		if err := m.Invalidate(""); err != nil {
+			return "", err
+		}
+		return m.loadToken(ctx, 0)
+	})
+}
+
+// loadToken 获取token 缓存中的token剩余有效期不超过 minRemaining 时重新获取
+// 多个实例共享缓存时通过分布式租约保证只有一个实例调用接口 调用方需要通过 flight 防止本实例并发获取
+func (m *tokenManager) loadToken(ctx context.Context, minRemaining time.Duration) (string, error) {
+	if m.withoutLease {
+		return m.refresh(ctx)
+	}
+	config := m.config()
+	lease := newTokenLease(config.cache, m.GetCacheKey(), config.leaseTimeout, config.leasePollInterval)
+	return lease.load(ctx, func() (string, bool, error) {
+		return cachedToken(ctx, config.cache, m.GetCacheKey(), minRemaining, config.logger)
+	}, m.refresh)
+}
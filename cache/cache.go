@@ -1,7 +1,7 @@
 package cache
 
 import (
-	"sync"
+	"reflect"
 	"time"
 )
 
@@ -13,71 +13,25 @@ type Cache interface {
 	Delete(key string) error
 }
 
-// data 存储数据用的
-type data struct {
-	Data    interface{}
-	Expired time.Time
+// Locker 支持 key 不存在时才写入的缓存 用于实现跨进程的分布式租约 缓存组件可选实现
+type Locker interface {
+	// SetNX key 不存在或已过期时写入 返回是否写入成功
+	SetNX(key string, val interface{}, timeout time.Duration) (bool, error)
 }
 
-// Memory 实现一个内存缓存
-type Memory struct {
-	sync.Mutex // 读写锁
-	data       map[string]*data
+// CompareDeleter 支持值相等时才删除的缓存 用于持有者安全地释放租约 实现 Locker 的缓存组件可选实现
+type CompareDeleter interface {
+	// CompareAndDelete 缓存的值等于 val 时删除 返回是否删除成功 比较和删除是原子的
+	CompareAndDelete(key string, val interface{}) (bool, error)
 }
 
-// NewMemory 实例化一个内存缓存器
-func NewMemory() Cache {
-	return &Memory{
-		data: map[string]*data{},
+// compareAndDelete 缓存实现了 CompareDeleter 时原子地比较和删除 否则读取比较后再删除
+func compareAndDelete(c Cache, key string, val interface{}) (bool, error) {
+	if deleter, ok := c.(CompareDeleter); ok {
+		return deleter.CompareAndDelete(key, val)
 	}
-}
-
-// Get 获取缓存的值
-func (mem *Memory) Get(key string) interface{} {
-	if val, ok := mem.data[key]; ok {
-		// 判断缓存是否过期
-		if val.Expired.Before(time.Now()) {
-			// 删除这个key
-			mem.deleteKey(key)
-			return nil
-		}
-		return val.Data
-	}
-	return nil
-}
-
-// Set 设置一个值
-func (mem *Memory) Set(key string, val interface{}, timeout time.Duration) error {
-	mem.Lock()
-	defer mem.Unlock()
-	mem.data[key] = &data{
-		Data:    val,
-		Expired: time.Now().Add(timeout),
-	}
-	return nil
-}
-
-// IsExist 判断值是否存在
-func (mem *Memory) IsExist(key string) bool {
-	if val, ok := mem.data[key]; ok {
-		if val.Expired.Before(time.Now()) {
-			return false
-		}
-		return true
+	if !reflect.DeepEqual(c.Get(key), val) {
+		return false, nil
 	}
-	return false
-}
-
-// Delete 删除一个值
-func (mem *Memory) Delete(key string) error {
-	mem.deleteKey(key)
-	return nil
-}
-
-// deleteKey 删除一个缓存key
-func (mem *Memory) deleteKey(key string) {
-	mem.Lock()
-	defer mem.Unlock()
-	delete(mem.data, key)
-	return
+	return true, c.Delete(key)
 }
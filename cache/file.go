@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileLockName 目录锁的文件名 多个进程通过锁保证 SetNX 等读写的原子性
+const fileLockName = ".lock"
+
+// fileData 缓存文件的内容
+type fileData struct {
+	Key     string          `json:"key"`
+	Data    json.RawMessage `json:"data"`
+	Expired int64           `json:"expired"` // 过期时间 unix 纳秒
+}
+
+// File 使用文件保存的缓存 值使用json序列化 进程重启后token仍然有效
+type File struct {
+	dir  string
+	lock sync.Mutex // 同一进程内的写锁 跨进程使用文件锁
+}
+
+// NewFile 实例化一个文件缓存 dir 不存在时自动创建
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir}, nil
+}
+
+// Get 获取缓存的值 不存在 过期或出错时返回 nil
+func (f *File) Get(key string) interface{} {
+	val, _, err := f.get(key)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// GetContext 获取缓存的值 不存在或过期时返回 false 读取失败时返回错误
+func (f *File) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	return f.get(key)
+}
+
+// get 读取缓存 写入使用原子的重命名 读取时不需要加锁
+func (f *File) get(key string) (interface{}, bool, error) {
+	item, err := f.read(key)
+	if err != nil || item == nil {
+		return nil, false, err
+	}
+	var val interface{}
+	if err = json.Unmarshal(item.Data, &val); err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Set 设置一个值 timeout 不大于0时值立即过期
+func (f *File) Set(key string, val interface{}, timeout time.Duration) error {
+	return f.withLock(func() error {
+		if timeout <= 0 {
+			return f.remove(key)
+		}
+		return f.write(key, val, timeout)
+	})
+}
+
+// SetNX key 不存在或已过期时写入一个值
+func (f *File) SetNX(key string, val interface{}, timeout time.Duration) (ok bool, err error) {
+	err = f.withLock(func() error {
+		item, err := f.read(key)
+		if err != nil || item != nil || timeout <= 0 {
+			return err
+		}
+		ok = true
+		return f.write(key, val, timeout)
+	})
+	return ok && err == nil, err
+}
+
+// CompareAndDelete 缓存的值等于 val 时删除 值按json序列化后比较
+func (f *File) CompareAndDelete(key string, val interface{}) (ok bool, err error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	err = f.withLock(func() error {
+		item, err := f.read(key)
+		if err != nil || item == nil || !bytes.Equal(item.Data, data) {
+			return err
+		}
+		ok = true
+		return f.remove(key)
+	})
+	return ok && err == nil, err
+}
+
+// IsExist 判断值是否存在
+func (f *File) IsExist(key string) bool {
+	item, err := f.read(key)
+	return err == nil && item != nil
+}
+
+// Delete 删除一个值
+func (f *File) Delete(key string) error {
+	return f.withLock(func() error {
+		return f.remove(key)
+	})
+}
+
+// DeleteExpired 清理所有过期的缓存文件
+func (f *File) DeleteExpired() error {
+	return f.withLock(func() error {
+		paths, err := filepath.Glob(filepath.Join(f.dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixNano()
+		for _, path := range paths {
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var item fileData
+			if json.Unmarshal(raw, &item) != nil || item.Expired <= now {
+				_ = os.Remove(path)
+			}
+		}
+		return nil
+	})
+}
+
+// path 缓存文件的路径 key 可能包含特殊字符 使用md5作为文件名
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%x.json", md5.Sum([]byte(key))))
+}
+
+// read 读取未过期的缓存 不存在或过期时返回 nil
+func (f *File) read(key string) (*fileData, error) {
+	raw, err := ioutil.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item fileData
+	if err = json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+	if item.Key != key || item.Expired <= time.Now().UnixNano() {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// write 先写入临时文件再重命名 保证其他进程不会读到写了一半的文件 调用方需要持有锁
+func (f *File) write(key string, val interface{}, timeout time.Duration) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(fileData{Key: key, Data: data, Expired: time.Now().Add(timeout).UnixNano()})
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(raw); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+// remove 删除缓存文件 调用方需要持有锁
+func (f *File) remove(key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// withLock 持有进程内和跨进程的锁执行 fn
+func (f *File) withLock(fn func() error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	unlock, err := lockFile(filepath.Join(f.dir, fileLockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
@@ -0,0 +1,35 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	fileLockRetry = 10 * time.Millisecond // 获取锁的重试间隔
+	fileLockStale = 30 * time.Second      // 锁文件超过该时间未释放视为持有进程已退出
+)
+
+// lockFile 不支持 flock 的系统使用独占创建锁文件实现排他锁
+func lockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_ = file.Close()
+			return func() {
+				_ = os.Remove(path)
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > fileLockStale {
+			_ = os.Remove(path)
+			continue
+		}
+		time.Sleep(fileLockRetry)
+	}
+}
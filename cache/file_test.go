@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试文件缓存在多个实例间持久化 过期和 SetNX
+func TestFile_Cache(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ Locker = first
+	if err = first.Set("douyin_openapi_access_token_app", "0801121846.token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟下一次运行的进程
+	second, _ := NewFile(dir)
+	if val := second.Get("douyin_openapi_access_token_app"); val != "0801121846.token" {
+		t.Fatalf("got value %#v", val)
+	}
+	if !second.IsExist("douyin_openapi_access_token_app") || second.IsExist("missing") {
+		t.Fatalf("exists check failed")
+	}
+	_ = first.Set("short", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if second.Get("short") != nil {
+		t.Fatalf("key should be expired")
+	}
+	if err = second.DeleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if err = second.Delete("douyin_openapi_access_token_app"); err != nil || first.Get("douyin_openapi_access_token_app") != nil {
+		t.Fatalf("got a error %v", err)
+	}
+
+	var wins int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, _ := NewFile(dir)
+			if ok, err := c.SetNX("lease", i, time.Hour); err != nil {
+				t.Error(err)
+			} else if ok {
+				atomic.AddInt32(&wins, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Fatalf("got %d SetNX winners", wins)
+	}
+	_ = first.Set("owner", "owner1", time.Hour)
+	if ok, err := second.CompareAndDelete("owner", "owner2"); ok || err != nil || !first.IsExist("owner") {
+		t.Fatalf("got %v %v", ok, err)
+	}
+	if ok, err := second.CompareAndDelete("owner", "owner1"); !ok || err != nil || first.IsExist("owner") {
+		t.Fatalf("got %v %v", ok, err)
+	}
+}
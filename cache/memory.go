@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCleanupInterval = time.Minute // 默认清理过期缓存的间隔
+	evictSampleSize        = 16          // 超出最大数量时检查是否过期的最久未使用的缓存数量
+)
+
+// MemoryConfig 内存缓存配置
+type MemoryConfig struct {
+	CleanupInterval time.Duration // 后台清理过期缓存的间隔 为0时使用默认的1分钟 小于0时不启动后台清理
+	MaxEntries      int           // 最大缓存数量 超出时先清理最久未使用的部分缓存中过期的缓存 再淘汰最久未使用的缓存 为0时不限制
+}
+
+// MemoryStats 内存缓存统计
+type MemoryStats struct {
+	Hits        uint64 // 命中次数
+	Misses      uint64 // 未命中次数
+	Evictions   uint64 // 超出最大数量被淘汰的次数
+	Expirations uint64 // 过期被清理的次数
+	Entries     int    // 当前缓存数量 包含还未清理的过期缓存
+}
+
+// data 存储数据用的
+type data struct {
+	Key     string
+	Data    interface{}
+	Expired time.Time
+}
+
+// Memory 实现一个内存缓存 使用完后调用 Close 停止后台清理
+type Memory struct {
+	*memory // 后台清理只引用内部结构 Memory 被回收时自动停止清理
+}
+
+// memory 内存缓存的实现
+type memory struct {
+	lock        sync.RWMutex // 读写锁
+	data        map[string]*list.Element
+	lru         *list.List // 最近使用的在前面
+	maxEntries  int
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	stop        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewMemory 实例化一个内存缓存器
+func NewMemory() Cache {
+	return NewMemoryWithConfig(MemoryConfig{})
+}
+
+// NewMemoryWithConfig 使用指定配置实例化一个内存缓存器
+func NewMemoryWithConfig(config MemoryConfig) *Memory {
+	mem := &memory{
+		data:       map[string]*list.Element{},
+		lru:        list.New(),
+		maxEntries: config.MaxEntries,
+		stop:       make(chan struct{}),
+	}
+	interval := config.CleanupInterval
+	if interval == 0 {
+		interval = defaultCleanupInterval
+	}
+	if interval > 0 {
+		go mem.janitor(interval)
+	}
+	wrapper := &Memory{mem}
+	runtime.SetFinalizer(wrapper, func(wrapper *Memory) {
+		wrapper.Close()
+	})
+	return wrapper
+}
+
+// Get 获取缓存的值
+func (mem *memory) Get(key string) interface{} {
+	// 限制数量时需要调整使用顺序
+	if mem.maxEntries > 0 {
+		mem.lock.Lock()
+		defer mem.lock.Unlock()
+	} else {
+		mem.lock.RLock()
+		defer mem.lock.RUnlock()
+	}
+	val, ok := mem.get(key)
+	if !ok {
+		atomic.AddUint64(&mem.misses, 1)
+		return nil
+	}
+	atomic.AddUint64(&mem.hits, 1)
+	return val.Data
+}
+
+// GetContext 获取缓存的值 不存在时返回 false
+func (mem *memory) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	val := mem.Get(key)
+	return val, val != nil, nil
+}
+
+// get 获取未过期的缓存 调用方需要持有锁
+func (mem *memory) get(key string) (*data, bool) {
+	element, ok := mem.data[key]
+	if !ok {
+		return nil, false
+	}
+	val := element.Value.(*data)
+	// 判断缓存是否过期 过期的缓存由后台清理
+	if val.Expired.Before(time.Now()) {
+		return nil, false
+	}
+	if mem.maxEntries > 0 {
+		mem.lru.MoveToFront(element)
+	}
+	return val, true
+}
+
+// Set 设置一个值
+func (mem *memory) Set(key string, val interface{}, timeout time.Duration) error {
+	mem.lock.Lock()
+	defer mem.lock.Unlock()
+	mem.set(key, val, timeout)
+	return nil
+}
+
+// set 写入缓存 超出最大数量时淘汰缓存 调用方需要持有写锁
+func (mem *memory) set(key string, val interface{}, timeout time.Duration) {
+	item := &data{
+		Key:     key,
+		Data:    val,
+		Expired: time.Now().Add(timeout),
+	}
+	if element, ok := mem.data[key]; ok {
+		element.Value = item
+		mem.lru.MoveToFront(element)
+		return
+	}
+	mem.data[key] = mem.lru.PushFront(item)
+	if mem.maxEntries > 0 && mem.lru.Len() > mem.maxEntries {
+		mem.evict(time.Now())
+	}
+}
+
+// evict 超出最大数量时淘汰缓存 调用方需要持有写锁
+// 先清理刚写入的和最久未使用的 evictSampleSize 个缓存中过期的缓存 仍然超出时淘汰最久未使用的缓存 不扫描全部缓存
+func (mem *memory) evict(now time.Time) {
+	if front := mem.lru.Front(); front.Value.(*data).Expired.Before(now) {
+		mem.removeElement(front)
+		atomic.AddUint64(&mem.expirations, 1)
+	}
+	element := mem.lru.Back()
+	for n := 0; element != nil && n < evictSampleSize && mem.lru.Len() > mem.maxEntries; n++ {
+		prev := element.Prev()
+		if element.Value.(*data).Expired.Before(now) {
+			mem.removeElement(element)
+			atomic.AddUint64(&mem.expirations, 1)
+		}
+		element = prev
+	}
+	for mem.lru.Len() > mem.maxEntries {
+		mem.removeElement(mem.lru.Back())
+		atomic.AddUint64(&mem.evictions, 1)
+	}
+}
+
+// SetNX key 不存在或已过期时写入一个值
+func (mem *memory) SetNX(key string, val interface{}, timeout time.Duration) (bool, error) {
+	mem.lock.Lock()
+	defer mem.lock.Unlock()
+	if _, ok := mem.get(key); ok {
+		return false, nil
+	}
+	mem.set(key, val, timeout)
+	return true, nil
+}
+
+// CompareAndDelete 缓存的值等于 val 时删除
+func (mem *memory) CompareAndDelete(key string, val interface{}) (bool, error) {
+	mem.lock.Lock()
+	defer mem.lock.Unlock()
+	item, ok := mem.get(key)
+	if !ok || !reflect.DeepEqual(item.Data, val) {
+		return false, nil
+	}
+	mem.removeElement(mem.data[key])
+	return true, nil
+}
+
+// IsExist 判断值是否存在
+func (mem *memory) IsExist(key string) bool {
+	mem.lock.RLock()
+	defer mem.lock.RUnlock()
+	if element, ok := mem.data[key]; ok {
+		return !element.Value.(*data).Expired.Before(time.Now())
+	}
+	return false
+}
+
+// Delete 删除一个值
+func (mem *memory) Delete(key string) error {
+	mem.lock.Lock()
+	defer mem.lock.Unlock()
+	if element, ok := mem.data[key]; ok {
+		mem.removeElement(element)
+	}
+	return nil
+}
+
+// Stats 获取缓存统计
+func (mem *memory) Stats() MemoryStats {
+	mem.lock.RLock()
+	entries := len(mem.data)
+	mem.lock.RUnlock()
+	return MemoryStats{
+		Hits:        atomic.LoadUint64(&mem.hits),
+		Misses:      atomic.LoadUint64(&mem.misses),
+		Evictions:   atomic.LoadUint64(&mem.evictions),
+		Expirations: atomic.LoadUint64(&mem.expirations),
+		Entries:     entries,
+	}
+}
+
+// DeleteExpired 清理所有过期的缓存
+func (mem *memory) DeleteExpired() {
+	now := time.Now()
+	mem.lock.Lock()
+	defer mem.lock.Unlock()
+	for _, element := range mem.data {
+		if element.Value.(*data).Expired.Before(now) {
+			mem.removeElement(element)
+			atomic.AddUint64(&mem.expirations, 1)
+		}
+	}
+}
+
+// Close 停止后台清理 可以重复调用 关闭后缓存仍然可用
+func (mem *memory) Close() error {
+	mem.closeOnce.Do(func() {
+		close(mem.stop)
+	})
+	return nil
+}
+
+// removeElement 删除一个缓存 调用方需要持有写锁
+func (mem *memory) removeElement(element *list.Element) {
+	mem.lru.Remove(element)
+	delete(mem.data, element.Value.(*data).Key)
+}
+
+// janitor 定时清理过期的缓存
+func (mem *memory) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mem.DeleteExpired()
+		case <-mem.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试并发读写 需要配合 -race 运行
+func TestMemory_Concurrent(t *testing.T) {
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: time.Millisecond})
+	defer mem.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("key_%d", j%10)
+				_ = mem.Set(key, i, time.Millisecond)
+				mem.Get(key)
+				mem.IsExist(key)
+				_, _ = mem.SetNX(key, i, time.Second)
+				if j%7 == 0 {
+					_ = mem.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// 测试过期清理和最大数量淘汰
+func TestMemory_ExpireAndEvict(t *testing.T) {
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1, MaxEntries: 2})
+	defer mem.Close()
+	_ = mem.Set("a", "1", time.Hour)
+	_ = mem.Set("b", "2", time.Hour)
+	mem.Get("a")
+	_ = mem.Set("c", "3", time.Hour)
+	if mem.Get("b") != nil || mem.Get("a") != "1" || mem.Get("c") != "3" {
+		t.Fatalf("least recently used key should be evicted")
+	}
+	// 超出数量时先清理过期的缓存 不淘汰未过期的缓存
+	_ = mem.Set("d", "4", -time.Second)
+	if mem.IsExist("d") || mem.Get("d") != nil {
+		t.Fatalf("expired key should not exist")
+	}
+	mem.DeleteExpired()
+	stats := mem.Stats()
+	if stats.Hits != 3 || stats.Misses != 2 || stats.Evictions != 1 || stats.Expirations != 1 || stats.Entries != 2 {
+		t.Fatalf("got stats %+v", stats)
+	}
+	if !mem.IsExist("a") || !mem.IsExist("c") {
+		t.Fatalf("expired key should not evict live keys")
+	}
+	if ok, _ := mem.SetNX("c", "5", time.Hour); ok {
+		t.Fatalf("SetNX should fail on existing key")
+	}
+	if ok, _ := mem.SetNX("e", "5", time.Hour); !ok || mem.Get("e") != "5" {
+		t.Fatalf("SetNX should write missing key")
+	}
+	if ok, _ := mem.CompareAndDelete("e", "6"); ok || mem.Get("e") != "5" {
+		t.Fatalf("CompareAndDelete should keep a different value")
+	}
+	if ok, _ := mem.CompareAndDelete("e", "5"); !ok || mem.IsExist("e") {
+		t.Fatalf("CompareAndDelete should delete an equal value")
+	}
+}
+
+// 测试超出最大数量时优先清理最久未使用的缓存中过期的缓存
+func TestMemory_EvictExpiredFirst(t *testing.T) {
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1, MaxEntries: 3})
+	defer mem.Close()
+	_ = mem.Set("a", "1", time.Hour)
+	_ = mem.Set("b", "2", time.Millisecond)
+	_ = mem.Set("c", "3", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	_ = mem.Set("d", "4", time.Hour)
+	if !mem.IsExist("a") || mem.IsExist("b") || !mem.IsExist("c") || !mem.IsExist("d") {
+		t.Fatalf("expired key should be removed before live keys")
+	}
+	if stats := mem.Stats(); stats.Evictions != 0 || stats.Expirations != 1 || stats.Entries != 3 {
+		t.Fatalf("got stats %+v", stats)
+	}
+}
+
+// 超出最大数量后写入的耗时不随缓存数量增长
+func BenchmarkMemory_SetOverCapacity(b *testing.B) {
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1, MaxEntries: 100000})
+	defer mem.Close()
+	for i := 0; i < 100000; i++ {
+		_ = mem.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = mem.Set("new_"+strconv.Itoa(i), i, time.Hour)
+	}
+}
+
+// 测试后台清理过期缓存
+func TestMemory_Janitor(t *testing.T) {
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: time.Millisecond})
+	defer mem.Close()
+	_ = mem.Set("a", "1", time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for mem.Stats().Entries != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not remove expired key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_ = mem.Close()
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Namespace 给所有的key加上前缀 多个环境和应用共用一个缓存时互不冲突
+type Namespace struct {
+	Cache  Cache  // 实际的缓存
+	Prefix string // key 前缀 例如 sandbox: 或 app1:
+}
+
+// lockerNamespace 实际的缓存实现了 Locker 的命名空间
+type lockerNamespace struct {
+	*Namespace
+}
+
+// NewNamespace 实例化一个带前缀的缓存 实际的缓存实现 Locker 时返回值也实现 Locker
+func NewNamespace(c Cache, prefix string) Cache {
+	if c == nil {
+		panic(any("cache is need"))
+	}
+	namespace := &Namespace{Cache: c, Prefix: prefix}
+	if _, ok := c.(Locker); ok {
+		return lockerNamespace{namespace}
+	}
+	return namespace
+}
+
+// Get 获取缓存的值
+func (n *Namespace) Get(key string) interface{} {
+	return n.Cache.Get(n.Prefix + key)
+}
+
+// GetContext 获取缓存的值
+func (n *Namespace) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	return Lookup(ctx, n.Cache, n.Prefix+key)
+}
+
+// Set 设置一个值
+func (n *Namespace) Set(key string, val interface{}, timeout time.Duration) error {
+	return n.Cache.Set(n.Prefix+key, val, timeout)
+}
+
+// IsExist 判断值是否存在
+func (n *Namespace) IsExist(key string) bool {
+	return n.Cache.IsExist(n.Prefix + key)
+}
+
+// Delete 删除一个值
+func (n *Namespace) Delete(key string) error {
+	return n.Cache.Delete(n.Prefix + key)
+}
+
+// SetNX key 不存在时写入一个值
+func (n lockerNamespace) SetNX(key string, val interface{}, timeout time.Duration) (bool, error) {
+	return n.Cache.(Locker).SetNX(n.Prefix+key, val, timeout)
+}
+
+// CompareAndDelete 缓存的值等于 val 时删除
+func (n lockerNamespace) CompareAndDelete(key string, val interface{}) (bool, error) {
+	return compareAndDelete(n.Cache, n.Prefix+key, val)
+}
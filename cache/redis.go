@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisConfig redis 缓存配置
+type RedisConfig struct {
+	Addr         string        // 地址 例如 127.0.0.1:6379
+	Password     string        // 密码 为空时不认证
+	DB           int           // 数据库
+	KeyPrefix    string        // key 前缀 多个应用共用一个 redis 时区分
+	DialTimeout  time.Duration // 连接超时 默认5秒
+	ReadTimeout  time.Duration // 读写超时 默认3秒
+	MaxIdleConns int           // 最大空闲连接数 默认10
+}
+
+// RedisError redis 返回的错误
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// errRedisNil redis 返回的空值
+var errRedisNil = errors.New("redis: nil")
+
+// Redis 使用 redis 协议的缓存 值使用json序列化 多个实例可共享token
+type Redis struct {
+	config RedisConfig
+	idle   chan *redisConn
+	lock   sync.Mutex
+	closed bool
+}
+
+// redisConn 一个 redis 连接
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedis 实例化一个 redis 缓存 连接在第一次使用时建立
+func NewRedis(config RedisConfig) *Redis {
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = 3 * time.Second
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 10
+	}
+	return &Redis{
+		config: config,
+		idle:   make(chan *redisConn, config.MaxIdleConns),
+	}
+}
+
+// Get 获取缓存的值 不存在或出错时返回 nil
+func (r *Redis) Get(key string) interface{} {
+	val, err := r.get(key)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// GetContext 获取缓存的值 不存在时返回 false 连接和解析失败时返回错误
+func (r *Redis) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	val, err := r.get(key)
+	return val, val != nil, err
+}
+
+// get 获取缓存的值 不存在时返回 nil 和 nil 错误
+func (r *Redis) get(key string) (interface{}, error) {
+	reply, err := r.do("GET", r.config.KeyPrefix+key)
+	if err == errRedisNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T", reply)
+	}
+	var val interface{}
+	if err = json.Unmarshal(raw, &val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// Set 设置一个值 timeout 不大于0时值立即过期
+func (r *Redis) Set(key string, val interface{}, timeout time.Duration) error {
+	if timeout <= 0 {
+		return r.Delete(key)
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	_, err = r.do("SET", r.config.KeyPrefix+key, string(raw), "PX", formatMs(timeout))
+	return err
+}
+
+// SetNX key 不存在时写入一个值
+func (r *Redis) SetNX(key string, val interface{}, timeout time.Duration) (bool, error) {
+	if timeout <= 0 {
+		return false, nil
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	_, err = r.do("SET", r.config.KeyPrefix+key, string(raw), "NX", "PX", formatMs(timeout))
+	if err == errRedisNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// compareAndDeleteScript 值相等时删除的 lua 脚本 保证比较和删除的原子性
+const compareAndDeleteScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// CompareAndDelete 缓存的值等于 val 时删除 值按json序列化后比较
+func (r *Redis) CompareAndDelete(key string, val interface{}) (bool, error) {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	reply, err := r.do("EVAL", compareAndDeleteScript, "1", r.config.KeyPrefix+key, string(raw))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n > 0, nil
+}
+
+// IsExist 判断值是否存在
+func (r *Redis) IsExist(key string) bool {
+	reply, err := r.do("EXISTS", r.config.KeyPrefix+key)
+	if err != nil {
+		return false
+	}
+	n, _ := reply.(int64)
+	return n > 0
+}
+
+// Delete 删除一个值
+func (r *Redis) Delete(key string) error {
+	_, err := r.do("DEL", r.config.KeyPrefix+key)
+	return err
+}
+
+// Close 关闭所有空闲连接
+func (r *Redis) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.idle)
+	for conn := range r.idle {
+		_ = conn.conn.Close()
+	}
+	return nil
+}
+
+// do 执行一条命令 出错的连接直接关闭 redis 返回的错误不影响连接复用
+func (r *Redis) do(args ...string) (interface{}, error) {
+	conn, err := r.getConn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(r.config.ReadTimeout, args...)
+	var redisError RedisError
+	if err != nil && err != errRedisNil && !errors.As(err, &redisError) {
+		_ = conn.conn.Close()
+		return nil, err
+	}
+	r.putConn(conn)
+	return reply, err
+}
+
+// getConn 获取一个空闲连接 没有时新建连接
+func (r *Redis) getConn() (*redisConn, error) {
+	select {
+	case conn, ok := <-r.idle:
+		if ok {
+			return conn, nil
+		}
+		return nil, errors.New("redis: client closed")
+	default:
+	}
+	netConn, err := net.DialTimeout("tcp", r.config.Addr, r.config.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+	if r.config.Password != "" {
+		if _, err = conn.do(r.config.ReadTimeout, "AUTH", r.config.Password); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+	}
+	if r.config.DB != 0 {
+		if _, err = conn.do(r.config.ReadTimeout, "SELECT", strconv.Itoa(r.config.DB)); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// putConn 归还连接 空闲连接已满或已关闭时关闭连接
+func (r *Redis) putConn(conn *redisConn) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.closed {
+		select {
+		case r.idle <- conn:
+			return
+		default:
+		}
+	}
+	_ = conn.conn.Close()
+}
+
+// do 发送命令并读取返回值
+func (c *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.reader)
+}
+
+// readReply 读取一个 RESP 返回值 空值返回 errRedisNil
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		raw := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, raw); err != nil {
+			return nil, err
+		}
+		return raw[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			items[i], err = readReply(reader)
+			if err != nil && err != errRedisNil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
+
+// formatMs 转换为毫秒 不足1毫秒按1毫秒
+func formatMs(timeout time.Duration) string {
+	ms := int64(timeout / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
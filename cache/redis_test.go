@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respServer 测试用的进程内 RESP 服务 支持 AUTH SELECT GET SET DEL EXISTS
+type respServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	data     map[string]string
+	expired  map[string]time.Time
+	password string
+}
+
+// newRespServer 启动一个进程内的 RESP 服务
+func newRespServer(t *testing.T, password string) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &respServer{listener: listener, data: map[string]string{}, expired: map[string]time.Time{}, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+// serve 处理一个连接的命令
+func (s *respServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			raw, _ := item.([]byte)
+			args[i] = string(raw)
+		}
+		if len(args) == 0 {
+			return
+		}
+		if !authed && strings.ToUpper(args[0]) != "AUTH" {
+			_, _ = conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			continue
+		}
+		if strings.ToUpper(args[0]) == "AUTH" {
+			authed = args[1] == s.password
+		}
+		_, _ = conn.Write([]byte(s.exec(args)))
+	}
+}
+
+// exec 执行一条命令并返回 RESP 格式的结果
+func (s *respServer) exec(args []string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	exists := func(key string) bool {
+		if _, ok := s.data[key]; !ok {
+			return false
+		}
+		if expired, ok := s.expired[key]; ok && !expired.After(time.Now()) {
+			delete(s.data, key)
+			delete(s.expired, key)
+			return false
+		}
+		return true
+	}
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if !exists(args[1]) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(s.data[args[1]]), s.data[args[1]])
+	case "SET":
+		nx, expired := false, time.Time{}
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				expired = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		if nx && exists(args[1]) {
+			return "$-1\r\n"
+		}
+		s.data[args[1]] = args[2]
+		s.expired[args[1]] = expired
+		if expired.IsZero() {
+			delete(s.expired, args[1])
+		}
+		return "+OK\r\n"
+	case "EVAL":
+		// 只支持 CompareAndDelete 使用的脚本
+		if args[1] != compareAndDeleteScript || args[2] != "1" {
+			return "-ERR unknown script\r\n"
+		}
+		if !exists(args[3]) || s.data[args[3]] != args[4] {
+			return ":0\r\n"
+		}
+		delete(s.data, args[3])
+		delete(s.expired, args[3])
+		return ":1\r\n"
+	case "DEL", "EXISTS":
+		n := 0
+		for _, key := range args[1:] {
+			if exists(key) {
+				n++
+				if strings.ToUpper(args[0]) == "DEL" {
+					delete(s.data, key)
+					delete(s.expired, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+// 测试 redis 缓存的读写 过期 前缀和 SetNX
+func TestRedis_Cache(t *testing.T) {
+	server := newRespServer(t, "pass")
+	redis := NewRedis(RedisConfig{Addr: server.listener.Addr().String(), Password: "pass", DB: 1, KeyPrefix: "app1:"})
+	defer redis.Close()
+	var _ Locker = redis
+
+	if err := redis.Set("token", "0801121846.token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if val := redis.Get("token"); val != "0801121846.token" {
+		t.Fatalf("got value %#v", val)
+	}
+	server.lock.Lock()
+	_, ok := server.data["app1:token"]
+	server.lock.Unlock()
+	if !ok {
+		t.Fatalf("key prefix not applied %v", server.data)
+	}
+	expiresAt := time.Now().Unix()
+	_ = redis.Set("token_expires_at", expiresAt, time.Hour)
+	if val, _ := redis.Get("token_expires_at").(float64); int64(val) != expiresAt {
+		t.Fatalf("got value %#v", redis.Get("token_expires_at"))
+	}
+	if !redis.IsExist("token") || redis.IsExist("missing") || redis.Get("missing") != nil {
+		t.Fatalf("exists check failed")
+	}
+	if ok, err := redis.SetNX("lease", "owner1", time.Hour); !ok || err != nil {
+		t.Fatalf("got %v %v", ok, err)
+	}
+	if ok, err := redis.SetNX("lease", "owner2", time.Hour); ok || err != nil {
+		t.Fatalf("got %v %v", ok, err)
+	}
+	if ok, err := redis.CompareAndDelete("lease", "owner2"); ok || err != nil || !redis.IsExist("lease") {
+		t.Fatalf("got %v %v", ok, err)
+	}
+	if ok, err := redis.CompareAndDelete("lease", "owner1"); !ok || err != nil || redis.IsExist("lease") {
+		t.Fatalf("got %v %v", ok, err)
+	}
+	if err := redis.Delete("token"); err != nil || redis.IsExist("token") {
+		t.Fatalf("got a error %v", err)
+	}
+	_ = redis.Set("short", "1", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if redis.Get("short") != nil {
+		t.Fatalf("key should be expired")
+	}
+
+	wrong := NewRedis(RedisConfig{Addr: server.listener.Addr().String(), Password: "wrong"})
+	defer wrong.Close()
+	if err := wrong.Set("token", "1", time.Hour); err == nil {
+		t.Fatalf("auth should fail")
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// defaultL1Timeout 一级缓存默认的有效期
+const defaultL1Timeout = 5 * time.Second
+
+// Tiered 两级缓存 读取时优先使用进程内的一级缓存 写入和删除同时作用于两级缓存
+// 一级缓存的有效期较短 其他实例在二级缓存中刷新的值最多延迟 L1Timeout 可见
+type Tiered struct {
+	L1        Cache         // 一级缓存 一般为 Memory
+	L2        Cache         // 二级缓存 一般为多个实例共享的 Redis
+	L1Timeout time.Duration // 一级缓存的有效期
+}
+
+// lockerTiered 二级缓存实现了 Locker 的两级缓存
+type lockerTiered struct {
+	*Tiered
+}
+
+// NewTiered 实例化一个两级缓存 l1Timeout 不大于0时默认5秒 二级缓存实现 Locker 时返回值也实现 Locker
+func NewTiered(l1, l2 Cache, l1Timeout time.Duration) Cache {
+	if l1 == nil || l2 == nil {
+		panic(any("cache is need"))
+	}
+	if l1Timeout <= 0 {
+		l1Timeout = defaultL1Timeout
+	}
+	tiered := &Tiered{L1: l1, L2: l2, L1Timeout: l1Timeout}
+	if _, ok := l2.(Locker); ok {
+		return lockerTiered{tiered}
+	}
+	return tiered
+}
+
+// Get 获取缓存的值 一级缓存不存在时读取二级缓存并写入一级缓存
+func (t *Tiered) Get(key string) interface{} {
+	if val := t.L1.Get(key); val != nil {
+		return val
+	}
+	val := t.L2.Get(key)
+	if val != nil {
+		_ = t.L1.Set(key, val, t.L1Timeout)
+	}
+	return val
+}
+
+// GetContext 获取缓存的值 一级缓存读取失败时使用二级缓存 返回二级缓存的读取错误
+func (t *Tiered) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	if val, ok, err := Lookup(ctx, t.L1, key); err == nil && ok {
+		return val, true, nil
+	}
+	val, ok, err := Lookup(ctx, t.L2, key)
+	if ok {
+		_ = t.L1.Set(key, val, t.L1Timeout)
+	}
+	return val, ok, err
+}
+
+// Set 设置一个值 先写入二级缓存
+func (t *Tiered) Set(key string, val interface{}, timeout time.Duration) error {
+	if err := t.L2.Set(key, val, timeout); err != nil {
+		_ = t.L1.Delete(key)
+		return err
+	}
+	return t.L1.Set(key, val, t.l1Timeout(timeout))
+}
+
+// IsExist 判断值是否存在
+func (t *Tiered) IsExist(key string) bool {
+	return t.L1.IsExist(key) || t.L2.IsExist(key)
+}
+
+// Delete 删除一个值
+func (t *Tiered) Delete(key string) error {
+	if err := t.L2.Delete(key); err != nil {
+		_ = t.L1.Delete(key)
+		return err
+	}
+	return t.L1.Delete(key)
+}
+
+// l1Timeout 一级缓存的有效期不超过值本身的有效期
+func (t *Tiered) l1Timeout(timeout time.Duration) time.Duration {
+	if timeout < t.L1Timeout {
+		return timeout
+	}
+	return t.L1Timeout
+}
+
+// SetNX 只作用于二级缓存 保证多个实例间的互斥
+func (t lockerTiered) SetNX(key string, val interface{}, timeout time.Duration) (bool, error) {
+	ok, err := t.L2.(Locker).SetNX(key, val, timeout)
+	if ok {
+		_ = t.L1.Delete(key)
+	}
+	return ok, err
+}
+
+// CompareAndDelete 在二级缓存中比较和删除 删除成功后同时删除一级缓存
+func (t lockerTiered) CompareAndDelete(key string, val interface{}) (bool, error) {
+	ok, err := compareAndDelete(t.L2, key, val)
+	if ok {
+		_ = t.L1.Delete(key)
+	}
+	return ok, err
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// noLockCache 没有实现 Locker 的缓存
+type noLockCache struct {
+	Cache
+}
+
+// 测试两级缓存的读写和 Locker 透传
+func TestTiered_Cache(t *testing.T) {
+	l1 := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1})
+	l2 := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1})
+	tiered := NewTiered(l1, l2, time.Minute)
+	if err := tiered.Set("token", "token.1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if l1.Get("token") != "token.1" || l2.Get("token") != "token.1" {
+		t.Fatalf("set should write through")
+	}
+	// 其他实例写入二级缓存后 一级缓存过期前仍读到旧值
+	_ = l2.Set("token", "token.2", time.Hour)
+	if tiered.Get("token") != "token.1" {
+		t.Fatalf("l1 should serve hot reads")
+	}
+	_ = l1.Delete("token")
+	if tiered.Get("token") != "token.2" || l1.Get("token") != "token.2" {
+		t.Fatalf("l2 value should fill l1")
+	}
+	if err := tiered.Delete("token"); err != nil || tiered.IsExist("token") || l2.IsExist("token") {
+		t.Fatalf("delete should write through")
+	}
+	locker, ok := tiered.(Locker)
+	if !ok {
+		t.Fatalf("tiered should pass through Locker")
+	}
+	if ok, _ = locker.SetNX("lease", "1", time.Hour); !ok || l2.Get("lease") != "1" {
+		t.Fatalf("SetNX should write l2")
+	}
+	_ = l1.Set("lease", "1", time.Hour)
+	if ok, _ = tiered.(CompareDeleter).CompareAndDelete("lease", "2"); ok || !l2.IsExist("lease") {
+		t.Fatalf("CompareAndDelete should keep a different owner")
+	}
+	if ok, _ = tiered.(CompareDeleter).CompareAndDelete("lease", "1"); !ok || l1.IsExist("lease") || l2.IsExist("lease") {
+		t.Fatalf("CompareAndDelete should delete both levels")
+	}
+	if _, ok = NewTiered(l1, noLockCache{l2}, 0).(Locker); ok {
+		t.Fatalf("tiered should not be a Locker without l2 support")
+	}
+}
+
+// 测试命名空间隔离
+func TestNamespace_Cache(t *testing.T) {
+	shared := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1})
+	sandbox := NewNamespace(shared, "sandbox:")
+	production := NewNamespace(shared, "production:")
+	_ = sandbox.Set("douyin_openapi_access_token_app", "sandbox.token", time.Hour)
+	_ = production.Set("douyin_openapi_access_token_app", "production.token", time.Hour)
+	if sandbox.Get("douyin_openapi_access_token_app") != "sandbox.token" || production.Get("douyin_openapi_access_token_app") != "production.token" {
+		t.Fatalf("namespaces should not collide")
+	}
+	if shared.Get("sandbox:douyin_openapi_access_token_app") != "sandbox.token" {
+		t.Fatalf("prefix not applied")
+	}
+	_ = sandbox.Delete("douyin_openapi_access_token_app")
+	if sandbox.IsExist("douyin_openapi_access_token_app") || !production.IsExist("douyin_openapi_access_token_app") {
+		t.Fatalf("delete should only affect its namespace")
+	}
+	if _, ok := sandbox.(Locker); !ok {
+		t.Fatalf("namespace should pass through Locker")
+	}
+	_ = sandbox.Set("lease", "owner1", time.Hour)
+	if ok, _ := sandbox.(CompareDeleter).CompareAndDelete("lease", "owner1"); !ok || shared.IsExist("sandbox:lease") {
+		t.Fatalf("CompareAndDelete should apply the prefix")
+	}
+	if _, ok := NewNamespace(noLockCache{shared}, "app:").(Locker); ok {
+		t.Fatalf("namespace should not be a Locker without backend support")
+	}
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ContextGetter 支持 context 并区分未命中和读取失败的缓存 缓存组件可选实现
+type ContextGetter interface {
+	// GetContext 获取缓存的值 不存在时返回 false 和 nil 错误
+	GetContext(ctx context.Context, key string) (interface{}, bool, error)
+}
+
+// Lookup 获取缓存的值 缓存实现了 ContextGetter 时返回读取错误 否则 nil 视为未命中
+func Lookup(ctx context.Context, c Cache, key string) (interface{}, bool, error) {
+	if getter, ok := c.(ContextGetter); ok {
+		return getter.GetContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	val := c.Get(key)
+	return val, val != nil, nil
+}
+
+// Codec 缓存值的编解码
+type Codec[T any] interface {
+	Encode(val T) (interface{}, error)
+	Decode(val interface{}) (T, error)
+}
+
+// StringCodec 字符串编解码 兼容返回 []byte 和 json 字符串的缓存
+type StringCodec struct{}
+
+// Encode 原样保存字符串
+func (StringCodec) Encode(val string) (interface{}, error) {
+	return val, nil
+}
+
+// Decode 将缓存的值转换为字符串
+func (StringCodec) Decode(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case string:
+		return val, nil
+	case json.RawMessage:
+		var str string
+		if err := json.Unmarshal(val, &str); err != nil {
+			return "", err
+		}
+		return str, nil
+	case []byte:
+		return string(val), nil
+	}
+	return "", fmt.Errorf("cache: cannot decode %T as string", val)
+}
+
+// JSONCodec json 编解码 值以 json 字符串保存 适用于任意缓存
+type JSONCodec[T any] struct{}
+
+// Encode 序列化为 json 字符串
+func (JSONCodec[T]) Encode(val T) (interface{}, error) {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Decode 反序列化 字符串和 []byte 按 json 解析 缓存返回的是已解析的值时重新序列化后转换
+func (JSONCodec[T]) Decode(val interface{}) (result T, err error) {
+	var raw []byte
+	switch val := val.(type) {
+	case string:
+		raw = []byte(val)
+	case []byte:
+		raw = val
+	default:
+		if raw, err = json.Marshal(val); err != nil {
+			return
+		}
+	}
+	err = json.Unmarshal(raw, &result)
+	return
+}
+
+// ValueCodec 原样保存值 只适用于进程内的缓存
+type ValueCodec[T any] struct{}
+
+// Encode 原样保存
+func (ValueCodec[T]) Encode(val T) (interface{}, error) {
+	return val, nil
+}
+
+// Decode 断言为指定类型
+func (ValueCodec[T]) Decode(val interface{}) (result T, err error) {
+	result, ok := val.(T)
+	if !ok {
+		err = fmt.Errorf("cache: cannot decode %T as %T", val, result)
+	}
+	return
+}
+
+// TypedCache 指定值类型的缓存 读取失败和未命中分开返回 类型不符时返回错误而不是 panic
+type TypedCache[T any] struct {
+	Cache Cache    // 实际的缓存
+	Codec Codec[T] // 编解码
+}
+
+// NewTypedCache 实例化一个指定值类型的缓存
+func NewTypedCache[T any](c Cache, codec Codec[T]) *TypedCache[T] {
+	if c == nil {
+		panic(any("cache is need"))
+	}
+	return &TypedCache[T]{Cache: c, Codec: codec}
+}
+
+// Get 获取缓存的值 不存在时返回 false 和 nil 错误
+func (t *TypedCache[T]) Get(ctx context.Context, key string) (val T, ok bool, err error) {
+	raw, ok, err := Lookup(ctx, t.Cache, key)
+	if err != nil || !ok || raw == nil {
+		return val, false, err
+	}
+	val, err = t.Codec.Decode(raw)
+	if err != nil {
+		return val, false, err
+	}
+	return val, true, nil
+}
+
+// Set 设置一个值
+func (t *TypedCache[T]) Set(ctx context.Context, key string, val T, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	raw, err := t.Codec.Encode(val)
+	if err != nil {
+		return err
+	}
+	return t.Cache.Set(key, raw, timeout)
+}
+
+// Delete 删除一个值
+func (t *TypedCache[T]) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return t.Cache.Delete(key)
+}
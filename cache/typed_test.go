@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errCache 读取总是失败的缓存
+type errCache struct {
+	Cache
+}
+
+func (errCache) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+// 测试类型化缓存的编解码和错误区分
+func TestTypedCache_Get(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryWithConfig(MemoryConfig{CleanupInterval: -1})
+	strings := NewTypedCache[string](mem, StringCodec{})
+	for _, val := range []interface{}{"token", []byte("token"), json.RawMessage(`"token"`)} {
+		_ = mem.Set("key", val, time.Hour)
+		if got, ok, err := strings.Get(ctx, "key"); err != nil || !ok || got != "token" {
+			t.Fatalf("decode %T got %q %v %v", val, got, ok, err)
+		}
+	}
+	_ = mem.Set("key", 1, time.Hour)
+	if _, ok, err := strings.Get(ctx, "key"); err == nil || ok {
+		t.Fatalf("non-string value should return error")
+	}
+	if _, ok, err := strings.Get(ctx, "missing"); err != nil || ok {
+		t.Fatalf("miss should not return error")
+	}
+	if _, ok, err := NewTypedCache[string](errCache{mem}, StringCodec{}).Get(ctx, "key"); err == nil || ok {
+		t.Fatalf("backend error should be returned")
+	}
+
+	type user struct {
+		OpenId string `json:"open_id"`
+	}
+	users := NewTypedCache[user](mem, JSONCodec[user]{})
+	if err := users.Set(ctx, "user", user{OpenId: "open_1"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := users.Get(ctx, "user"); err != nil || !ok || got.OpenId != "open_1" {
+		t.Fatalf("got %+v %v %v", got, ok, err)
+	}
+	// 共享缓存返回已解析的 json 值
+	_ = mem.Set("user", map[string]interface{}{"open_id": "open_2"}, time.Hour)
+	if got, ok, err := users.Get(ctx, "user"); err != nil || !ok || got.OpenId != "open_2" {
+		t.Fatalf("got %+v %v %v", got, ok, err)
+	}
+	jsonStrings := NewTypedCache[string](mem, JSONCodec[string]{})
+	if got, ok, err := jsonStrings.Get(ctx, "missing"); err != nil || ok || got != "" {
+		t.Fatalf("got %q %v %v", got, ok, err)
+	}
+	if err := jsonStrings.Set(ctx, "str", "abc", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := jsonStrings.Get(ctx, "str"); err != nil || !ok || got != "abc" {
+		t.Fatalf("got %q %v %v", got, ok, err)
+	}
+	bytes := NewTypedCache[[]byte](mem, JSONCodec[[]byte]{})
+	_ = bytes.Set(ctx, "bytes", []byte("abc"), time.Hour)
+	if got, ok, err := bytes.Get(ctx, "bytes"); err != nil || !ok || string(got) != "abc" {
+		t.Fatalf("got %q %v %v", got, ok, err)
+	}
+}
@@ -1,43 +1,61 @@
 package douyin_openapi
 
 import (
-	"crypto/md5"
+	"context"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	accessToken "github.com/HeartGarlic/douyin-openapi/access-token"
 	"github.com/HeartGarlic/douyin-openapi/cache"
 	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"reflect"
 	"sort"
 	"strings"
 )
 
 const (
-	code2Session         = "/api/apps/v2/jscode2session"                                              // 小程序登录地址
-	createOrder          = "https://developer.toutiao.com/api/apps/ecpay/v1/create_order"             // 预下单
-	queryOrder           = "https://developer.toutiao.com/api/apps/ecpay/v1/query_order"              // 订单查询
-	createRefund         = "https://developer.toutiao.com/api/apps/ecpay/v1/create_refund"            // 退款
-	queryRefund          = "https://developer.toutiao.com/api/apps/ecpay/v1/query_refund"             // 退款结果查询
-	settle               = "https://developer.toutiao.com/api/apps/ecpay/v1/settle"                   // 结算
-	querySettle          = "https://developer.toutiao.com/api/apps/ecpay/v1/query_settle"             // 结算结果查询
-	unsettleAmount       = "https://developer.toutiao.com/api/apps/ecpay/v1/unsettle_amount"          // 可结算金额查询
-	createReturn         = "https://developer.toutiao.com/api/apps/ecpay/v1/create_return"            // 退分账
-	queryReturn          = "https://developer.toutiao.com/api/apps/ecpay/v1/query_return"             // 退分账结果查询
-	queryMerchantBalance = "https://developer.toutiao.com/api/apps/ecpay/saas/query_merchant_balance" // 商户余额查询
-	merchantWithdraw     = "https://developer.toutiao.com/api/apps/ecpay/saas/merchant_withdraw"      // 商户提现
-	queryWithdrawOrder   = "https://developer.toutiao.com/api/apps/ecpay/saas/query_withdraw_order"   // 提现结果查询
-	orderV2Push          = "https://developer.toutiao.com/api/apps/order/v2/push"                     // 订单推送
+	productionBaseApi = "https://developer.toutiao.com"   // 正式环境地址
+	sandboxBaseApi    = "https://open-sandbox.douyin.com" // 沙盒环境地址
 )
 
+const (
+	code2Session         = "/api/apps/v2/jscode2session"                 // 小程序登录地址
+	createOrder          = "/api/apps/ecpay/v1/create_order"             // 预下单
+	queryOrder           = "/api/apps/ecpay/v1/query_order"              // 订单查询
+	createRefund         = "/api/apps/ecpay/v1/create_refund"            // 退款
+	queryRefund          = "/api/apps/ecpay/v1/query_refund"             // 退款结果查询
+	settle               = "/api/apps/ecpay/v1/settle"                   // 结算
+	querySettle          = "/api/apps/ecpay/v1/query_settle"             // 结算结果查询
+	unsettleAmount       = "/api/apps/ecpay/v1/unsettle_amount"          // 可结算金额查询
+	createReturn         = "/api/apps/ecpay/v1/create_return"            // 退分账
+	queryReturn          = "/api/apps/ecpay/v1/query_return"             // 退分账结果查询
+	queryMerchantBalance = "/api/apps/ecpay/saas/query_merchant_balance" // 商户余额查询
+	merchantWithdraw     = "/api/apps/ecpay/saas/merchant_withdraw"      // 商户提现
+	queryWithdrawOrder   = "/api/apps/ecpay/saas/query_withdraw_order"   // 提现结果查询
+	orderV2Push          = "/api/apps/order/v2/push"                     // 订单推送
+)
+
+// signedPathPrefix 通用交易系统的接口路径前缀 配置了 Signer 时这些接口使用 RSA 签名
+const signedPathPrefix = "/api/trade_basic/"
+
 // DouYinOpenApiConfig 实例化配置
 type DouYinOpenApiConfig struct {
-	AppId       string
-	AppSecret   string
-	AccessToken accessToken.AccessToken
-	Cache       cache.Cache
-	IsSandbox   bool
-	Token       string
-	Salt        string
+	AppId        string
+	AppSecret    string
+	AccessToken  accessToken.AccessToken
+	Cache        cache.Cache
+	IsSandbox    bool
+	Token        string
+	Salt         string
+	HttpClient   util.Doer          // 自定义http客户端 可设置超时 代理 证书等 为空时使用默认客户端
+	Retry        *RetryPolicy       // 重试策略 为空时不重试
+	Interceptors []util.Interceptor // 请求拦截器 每次请求(包括重试和获取token)都会经过
+	Logger       util.Logger        // 日志 记录请求 回调和token刷新 敏感字段自动脱敏 为空时不输出日志
+	BaseApi      string             // 自定义接口地址 例如本地的mock服务 为空时根据 IsSandbox 选择正式或沙盒地址
+	Endpoints    map[string]string  // 单个接口的完整地址 key 为接口路径 例如 /api/apps/ecpay/v1/create_order
+	Signer       *util.RSASigner    // 通用交易系统的 RSA 签名 设置后 /api/trade_basic/ 下的接口自动添加 Byte-Authorization 请求头 并在设置平台公钥时验证返回值
+	SignedApis   map[string]bool    // 额外使用 RSA 签名的接口路径 例如 /api/apps/trade/v2/xxx 需要同时设置 Signer
 }
 
 // DouYinOpenApi 基类
@@ -51,35 +69,151 @@ func NewDouYinOpenApi(config DouYinOpenApiConfig) *DouYinOpenApi {
 	if config.Cache == nil {
 		config.Cache = cache.NewMemory()
 	}
-	if config.AccessToken == nil {
-		config.AccessToken = accessToken.NewDefaultAccessToken(config.AppId, config.AppSecret, config.Cache, config.IsSandbox)
+	if config.HttpClient == nil {
+		config.HttpClient = util.DefaultHttpClient
 	}
-	BaseApi := "https://developer.toutiao.com"
+	BaseApi := productionBaseApi
 	if config.IsSandbox {
-		BaseApi = "https://open-sandbox.douyin.com"
+		BaseApi = sandboxBaseApi
 	}
-	return &DouYinOpenApi{
+	if config.BaseApi != "" {
+		BaseApi = strings.TrimRight(config.BaseApi, "/")
+	}
+	d := &DouYinOpenApi{
 		Config:  config,
 		BaseApi: BaseApi,
 	}
+	if config.AccessToken == nil {
+		token := accessToken.NewDefaultAccessToken(config.AppId, config.AppSecret, config.Cache, config.IsSandbox).(*accessToken.DefaultAccessToken)
+		token.HttpClient = config.HttpClient
+		token.ApiUrl = d.GetApiUrl(accessToken.AccessTokenPath)
+		token.Interceptors = d.interceptors()
+		token.Logger = config.Logger
+		d.Config.AccessToken = token
+	}
+	return d
 }
 
-// GetApiUrl 获取api地址
+// NewAuthorizerOpenApi 实例化一个代授权小程序调用接口的抖音openapi实例
+// 授权小程序的 AccessToken 使用 authorizer_access_token 多个授权小程序共用同一个 component 管理类
+func NewAuthorizerOpenApi(component *accessToken.ComponentAccessToken, authorizerAppId string, config DouYinOpenApiConfig) *DouYinOpenApi {
+	config.AppId = authorizerAppId
+	if config.Cache == nil {
+		config.Cache = component.Cache
+	}
+	token := accessToken.NewAuthorizerAccessToken(component, authorizerAppId).(*accessToken.AuthorizerAccessToken)
+	token.Cache = config.Cache
+	config.AccessToken = token
+	return NewDouYinOpenApi(config)
+}
+
+// GetApiUrl 获取api地址 优先使用 Endpoints 中配置的地址
 func (d *DouYinOpenApi) GetApiUrl(url string) string {
+	if api, ok := d.Config.Endpoints[url]; ok && api != "" {
+		return api
+	}
 	return fmt.Sprintf("%s%s", d.BaseApi, url)
 }
 
+// interceptors 获取请求拦截器 配置了日志时在最内层记录请求日志
+func (d *DouYinOpenApi) interceptors() []util.Interceptor {
+	if d.Config.Logger == nil {
+		return d.Config.Interceptors
+	}
+	interceptors := make([]util.Interceptor, 0, len(d.Config.Interceptors)+1)
+	interceptors = append(interceptors, d.Config.Interceptors...)
+	return append(interceptors, util.LogInterceptor(d.Config.Logger))
+}
+
+// requestInterceptors 获取接口请求的拦截器 需要 RSA 签名的接口在日志前添加签名
+func (d *DouYinOpenApi) requestInterceptors(endpoint string) []util.Interceptor {
+	if !d.rsaSigned(endpoint) {
+		return d.interceptors()
+	}
+	interceptors := make([]util.Interceptor, 0, len(d.Config.Interceptors)+2)
+	interceptors = append(interceptors, d.Config.Interceptors...)
+	interceptors = append(interceptors, d.Config.Signer.Interceptor())
+	if d.Config.Logger != nil {
+		interceptors = append(interceptors, util.LogInterceptor(d.Config.Logger))
+	}
+	return interceptors
+}
+
+// rsaSigned 判断接口是否使用 RSA 签名 只有通用交易系统的接口和 SignedApis 中的接口签名
+func (d *DouYinOpenApi) rsaSigned(endpoint string) bool {
+	if d.Config.Signer == nil {
+		return false
+	}
+	return strings.HasPrefix(endpoint, signedPathPrefix) || d.Config.SignedApis[endpoint]
+}
+
+// logger 获取脱敏后的日志
+func (d *DouYinOpenApi) logger() util.Logger {
+	return util.NewRedactLogger(d.Config.Logger)
+}
+
+// logCallback 记录回调的解析结果
+func (d *DouYinOpenApi) logCallback(name, msgType, msg string, err error) {
+	fields := []util.Field{{Key: "callback", Value: name}, {Key: "type", Value: msgType}, {Key: "msg", Value: []byte(msg)}}
+	if err != nil {
+		d.logger().Log(context.Background(), util.LevelWarn, "douyin callback failed", append(fields, util.Field{Key: "error", Value: err})...)
+		return
+	}
+	d.logger().Log(context.Background(), util.LevelInfo, "douyin callback", fields...)
+}
+
 // PostJson 封装公共的请求方法
 func (d *DouYinOpenApi) PostJson(api string, params interface{}, response interface{}) (err error) {
-	body, err := util.PostJSON(api, params)
+	return d.PostJsonCtx(context.Background(), api, params, response)
+}
+
+// PostJsonCtx 封装公共的请求方法 支持传入context
+func (d *DouYinOpenApi) PostJsonCtx(ctx context.Context, api string, params interface{}, response interface{}) (err error) {
+	return d.doPost(ctx, util.EndpointName(api), api, nil, params, response)
+}
+
+// postEndpoint 请求内置的接口 endpoint 为接口路径 需要 access_token 的接口自动注入token
+func (d *DouYinOpenApi) postEndpoint(ctx context.Context, endpoint string, params interface{}, response interface{}) (err error) {
+	if placement, ok := tokenEndpoints[endpoint]; ok {
+		return d.postWithToken(ctx, endpoint, d.GetApiUrl(endpoint), placement, params, response)
+	}
+	return d.doPost(ctx, endpoint, d.GetApiUrl(endpoint), nil, params, response)
+}
+
+// doPost 发起请求 按重试策略重试失败的请求
+func (d *DouYinOpenApi) doPost(ctx context.Context, endpoint, api string, header http.Header, params interface{}, response interface{}) (err error) {
+	attempts := d.Config.Retry.attempts(endpoint)
+	for attempt := 1; ; attempt++ {
+		err = d.postOnce(ctx, endpoint, api, header, params, response)
+		if err == nil || attempt >= attempts || !d.Config.Retry.retryable(err) {
+			return
+		}
+		if waitErr := sleepContext(ctx, d.Config.Retry.backoff(attempt)); waitErr != nil {
+			return
+		}
+	}
+}
+
+// postOnce 发起一次请求
+func (d *DouYinOpenApi) postOnce(ctx context.Context, endpoint, api string, header http.Header, params interface{}, response interface{}) (err error) {
+	req := &util.Request{Endpoint: endpoint, Url: api, Params: params, Header: header.Clone()}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	invoker := util.ChainInterceptors(util.NewInvoker(d.Config.HttpClient), d.requestInterceptors(endpoint)...)
+	body, err := invoker(ctx, req)
 	if err != nil {
 		return
 	}
+	// 重试时清空上一次的返回值 避免上一次返回的字段残留
+	if value := reflect.ValueOf(response); value.Kind() == reflect.Ptr && !value.IsNil() {
+		value.Elem().Set(reflect.Zero(value.Elem().Type()))
+	}
 	err = json.Unmarshal(body, &response)
 	if err != nil {
 		return
 	}
-	return
+	return util.CheckResponse(endpoint, body)
 }
 
 // Code2SessionParams 小程序登录 所需参数
@@ -106,44 +240,43 @@ type Code2SessionResponseData struct {
 
 // Code2Session 小程序登录
 func (d *DouYinOpenApi) Code2Session(code, anonymousCode string) (code2SessionResponse Code2SessionResponse, err error) {
+	return d.Code2SessionCtx(context.Background(), code, anonymousCode)
+}
+
+// Code2SessionCtx 小程序登录 支持传入context
+func (d *DouYinOpenApi) Code2SessionCtx(ctx context.Context, code, anonymousCode string) (code2SessionResponse Code2SessionResponse, err error) {
 	params := Code2SessionParams{
 		Appid:         d.Config.AppId,
 		Secret:        d.Config.AppSecret,
 		AnonymousCode: anonymousCode,
 		Code:          code,
 	}
-	err = d.PostJson(d.GetApiUrl(code2Session), params, &code2SessionResponse)
-	if err != nil {
-		return
-	}
-	if code2SessionResponse.ErrNo != 0 {
-		return code2SessionResponse, fmt.Errorf("小程序登录错误: %s %d", code2SessionResponse.ErrTips, code2SessionResponse.ErrNo)
-	}
+	err = d.postEndpoint(ctx, code2Session, params, &code2SessionResponse)
 	return
 }
 
 // CreateOrderParams 预下单接口参数
 type CreateOrderParams struct {
-	AppId           string          `json:"app_id,omitempty"`            // app_id string 是 64 小程序APPID tt07e3715e98c9aac0
-	OutOrderNo      string          `json:"out_order_no,omitempty"`      // out_order_no string 是 64 开发者侧的订单号。 只能是数字、大小写字母_-*且在同一个app_id下唯一 7056505317450041644
-	TotalAmount     int64           `json:"total_amount,omitempty"`      // total_amount number 是 取值范围： [1,10000000000] 支付价格。 单位为[分] 100，即1元
-	Subject         string          `json:"subject,omitempty"`           // subject string 是 128 商品描述。 长度限制不超过 128 字节且不超过 42 字符 抖音商品XYZ
-	Body            string          `json:"body,omitempty"`              // body string 是 128 商品详情 长度限制不超过 128 字节且不超过 42 字符 抖音商品XYZ
-	ValidTime       int64           `json:"valid_time,omitempty"`        // valid_time number 是 取值范围： [300,172800] 订单过期时间(秒)。最小5分钟，最大2天，小于5分钟会被置为5分钟，大于2天会被置为2天 900，即15分钟
-	Sign            string          `json:"sign,omitempty"`              // sign string 是 344 签名，详见签名DEMO 21fc77aeeaad725d9500062a888888a2a3d
-	CpExtra         string          `json:"cp_extra,omitempty"`          // cp_extra string 否 2048 开发者自定义字段，回调原样回传。 超过最大长度会被截断 502205261403349
-	NotifyUrl       string          `json:"notify_url,omitempty"`        // notify_url string 否 256 商户自定义回调地址，必须以 https 开头，支持 443 端口。 指定时，支付成功后抖音会请求该地址通知开发者 https://api.iiyyeixin.com/Notify/bytedancePay
-	ThirdpartyId    string          `json:"thirdparty_id,omitempty"`     // thirdparty_id 条件选填 服务商模式接入必传 64 第三方平台服务商 id，非服务商模式留空 tt84a4f2177777e29df
-	StoreUid        string          `json:"store_uid,omitempty"`         // store_uid string 条件选填 多门店模式下可传 64 可用此字段指定本单使用的收款商户号（目前为灰度功能，需要联系平台运营添加白名单，白名单添加1小时后生效；未在白名单的小程序，该字段不生效） 70084531288883795888
-	DisableMsg      int             `json:"disable_msg,omitempty"`       // disable_msg number 否 是否屏蔽支付完成后推送用户抖音消息，1-屏蔽 0-非屏蔽，默认为0。 特别注意： 若接入POI, 请传1。因为POI订单体系会发消息，所以不用再接收一次担保支付推送消息， 1
-	MsgPage         string          `json:"msg_page,omitempty"`          // msg_page string 否 支付完成后推送给用户的抖音消息跳转页面，开发者需要传入在app.json中定义的链接，如果不传则跳转首页。 pages/orderDetail/orderDetail?no = DYMP8218048851499944448\u0026id = 797775
-	ExpandOrderInfo ExpandOrderInfo `json:"expand_order_info,omitempty"` // expand_order_info 否 - 订单拓展信息，详见下面 expand_order_info参数说明 { "original_delivery_fee":10, "actual_delivery_fee":10 }
-	LimitPayWay     string          `json:"limit_pay_way,omitempty"`     // limit_pay_way string 否 64 屏蔽指定支付方式，屏蔽多个支付方式，请使用逗号","分割，枚举值： 屏蔽微信支付：LIMIT_WX 屏蔽支付宝支付：LIMIT_ALI 屏蔽抖音支付：LIMIT_DYZF 特殊说明：若之前开通了白名单，平台会保留之前屏蔽逻辑；若传入该参数，会优先以传入的为准，白名单则无效 屏蔽抖音支付和微信支付： "LIMIT_DYZF,LIMIT_WX"
+	AppId           string           `json:"app_id,omitempty"`            // app_id string 是 64 小程序APPID tt07e3715e98c9aac0
+	OutOrderNo      string           `json:"out_order_no,omitempty"`      // out_order_no string 是 64 开发者侧的订单号。 只能是数字、大小写字母_-*且在同一个app_id下唯一 7056505317450041644
+	TotalAmount     int64            `json:"total_amount,omitempty"`      // total_amount number 是 取值范围： [1,10000000000] 支付价格。 单位为[分] 100，即1元
+	Subject         string           `json:"subject,omitempty"`           // subject string 是 128 商品描述。 长度限制不超过 128 字节且不超过 42 字符 抖音商品XYZ
+	Body            string           `json:"body,omitempty"`              // body string 是 128 商品详情 长度限制不超过 128 字节且不超过 42 字符 抖音商品XYZ
+	ValidTime       int64            `json:"valid_time,omitempty"`        // valid_time number 是 取值范围： [300,172800] 订单过期时间(秒)。最小5分钟，最大2天，小于5分钟会被置为5分钟，大于2天会被置为2天 900，即15分钟
+	Sign            string           `json:"sign,omitempty"`              // sign string 是 344 签名，详见签名DEMO 21fc77aeeaad725d9500062a888888a2a3d
+	CpExtra         string           `json:"cp_extra,omitempty"`          // cp_extra string 否 2048 开发者自定义字段，回调原样回传。 超过最大长度会被截断 502205261403349
+	NotifyUrl       string           `json:"notify_url,omitempty"`        // notify_url string 否 256 商户自定义回调地址，必须以 https 开头，支持 443 端口。 指定时，支付成功后抖音会请求该地址通知开发者 https://api.iiyyeixin.com/Notify/bytedancePay
+	ThirdpartyId    string           `json:"thirdparty_id,omitempty"`     // thirdparty_id 条件选填 服务商模式接入必传 64 第三方平台服务商 id，非服务商模式留空 tt84a4f2177777e29df
+	StoreUid        string           `json:"store_uid,omitempty"`         // store_uid string 条件选填 多门店模式下可传 64 可用此字段指定本单使用的收款商户号（目前为灰度功能，需要联系平台运营添加白名单，白名单添加1小时后生效；未在白名单的小程序，该字段不生效） 70084531288883795888
+	DisableMsg      int              `json:"disable_msg,omitempty"`       // disable_msg number 否 是否屏蔽支付完成后推送用户抖音消息，1-屏蔽 0-非屏蔽，默认为0。 特别注意： 若接入POI, 请传1。因为POI订单体系会发消息，所以不用再接收一次担保支付推送消息， 1
+	MsgPage         string           `json:"msg_page,omitempty"`          // msg_page string 否 支付完成后推送给用户的抖音消息跳转页面，开发者需要传入在app.json中定义的链接，如果不传则跳转首页。 pages/orderDetail/orderDetail?no = DYMP8218048851499944448\u0026id = 797775
+	ExpandOrderInfo *ExpandOrderInfo `json:"expand_order_info,omitempty"` // expand_order_info 否 - 订单拓展信息，详见下面 expand_order_info参数说明 { "original_delivery_fee":10, "actual_delivery_fee":10 }
+	LimitPayWay     string           `json:"limit_pay_way,omitempty"`     // limit_pay_way string 否 64 屏蔽指定支付方式，屏蔽多个支付方式，请使用逗号","分割，枚举值： 屏蔽微信支付：LIMIT_WX 屏蔽支付宝支付：LIMIT_ALI 屏蔽抖音支付：LIMIT_DYZF 特殊说明：若之前开通了白名单，平台会保留之前屏蔽逻辑；若传入该参数，会优先以传入的为准，白名单则无效 屏蔽抖音支付和微信支付： "LIMIT_DYZF,LIMIT_WX"
 }
 
 type ExpandOrderInfo struct {
-	OriginalDeliveryFee int
-	ActualDeliveryFee   int
+	OriginalDeliveryFee int `json:"original_delivery_fee"` // 配送费原价 单位分
+	ActualDeliveryFee   int `json:"actual_delivery_fee"`   // 实付配送费 单位分
 }
 
 // CreateOrderResponse 预下单返回值
@@ -160,44 +293,29 @@ type CreateOrderResponseData struct {
 
 // CreateOrder 预下单
 func (d *DouYinOpenApi) CreateOrder(params CreateOrderParams) (createOrderResponse CreateOrderResponse, err error) {
+	return d.CreateOrderCtx(context.Background(), params)
+}
+
+// CreateOrderCtx 预下单 支持传入context
+func (d *DouYinOpenApi) CreateOrderCtx(ctx context.Context, params CreateOrderParams) (createOrderResponse CreateOrderResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(createOrder, params, &createOrderResponse)
-	if err != nil {
-		return
-	}
-	if createOrderResponse.ErrNo != 0 {
-		return createOrderResponse, fmt.Errorf("%s %d", createOrderResponse.ErrTips, createOrderResponse.ErrNo)
-	}
+	err = d.postEndpoint(ctx, createOrder, params, &createOrderResponse)
 	return
 }
 
-// GenerateSign 生成请求签名
+// GenerateSign 生成请求签名 参数实现 SignFielder 时不经过json序列化 参数无法序列化时返回空字符串
 func (d *DouYinOpenApi) GenerateSign(params interface{}) string {
-	var paramsMap map[string]interface{}
-	var paramsArr []string
-	j, _ := json.Marshal(&params)
-	err := json.Unmarshal(j, &paramsMap)
+	if fielder, ok := params.(SignFielder); ok {
+		if sign, ok := fieldsSign(fielder, d.Config.Salt); ok {
+			return sign
+		}
+	}
+	plain, err := signPlain(params, d.Config.Salt)
 	if err != nil {
 		return ""
 	}
-	for k, v := range paramsMap {
-		if k == "other_settle_params" || k == "app_id" || k == "thirdparty_id" || k == "sign" || k == "salt" || k == "token" {
-			continue
-		}
-		value := strings.TrimSpace(fmt.Sprintf("%v", v))
-		if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") && len(value) > 1 {
-			value = value[1 : len(value)-1]
-		}
-		value = strings.TrimSpace(value)
-		if value == "" || value == "null" {
-			continue
-		}
-		paramsArr = append(paramsArr, value)
-	}
-	paramsArr = append(paramsArr, d.Config.Salt)
-	sort.Strings(paramsArr)
-	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(paramsArr, "&"))))
+	return md5Sign(plain)
 }
 
 // QueryOrderParams 订单查询接口参数
@@ -229,19 +347,18 @@ type PaymentInfo struct {
 
 // QueryOrder 支付结果查询
 func (d *DouYinOpenApi) QueryOrder(outOrderNo, thirdpartyId string) (queryOrderResponse QueryOrderResponse, err error) {
+	return d.QueryOrderCtx(context.Background(), outOrderNo, thirdpartyId)
+}
+
+// QueryOrderCtx 支付结果查询 支持传入context
+func (d *DouYinOpenApi) QueryOrderCtx(ctx context.Context, outOrderNo, thirdpartyId string) (queryOrderResponse QueryOrderResponse, err error) {
 	queryParams := QueryOrderParams{
 		AppId:        d.Config.AppId,
 		OutOrderNo:   outOrderNo,
 		ThirdpartyId: thirdpartyId,
 	}
 	queryParams.Sign = d.GenerateSign(queryParams)
-	err = d.PostJson(queryOrder, queryParams, &queryOrderResponse)
-	if err != nil {
-		return
-	}
-	if queryOrderResponse.ErrNo != 0 {
-		return queryOrderResponse, fmt.Errorf("%s %d", queryOrderResponse.ErrTips, queryOrderResponse.ErrNo)
-	}
+	err = d.postEndpoint(ctx, queryOrder, queryParams, &queryOrderResponse)
 	return
 }
 
@@ -283,6 +400,9 @@ func (d *DouYinOpenApi) CheckResponseSign(oldSign string, strArr []string) error
 
 // PayCallback 支付结果回调
 func (d *DouYinOpenApi) PayCallback(body string, checkSign bool) (payCallbackResponse PayCallbackResponse, err error) {
+	defer func() {
+		d.logCallback("PayCallback", payCallbackResponse.Type, payCallbackResponse.Msg, err)
+	}()
 	// 解析数据
 	err = json.Unmarshal([]byte(body), &payCallbackResponse)
 	if err != nil {
@@ -333,15 +453,14 @@ type CreateRefundResponse struct {
 
 // CreateRefund 发起退款
 func (d *DouYinOpenApi) CreateRefund(params CreateRefundParams) (createRefundResponse CreateRefundResponse, err error) {
+	return d.CreateRefundCtx(context.Background(), params)
+}
+
+// CreateRefundCtx 发起退款 支持传入context
+func (d *DouYinOpenApi) CreateRefundCtx(ctx context.Context, params CreateRefundParams) (createRefundResponse CreateRefundResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(createRefund, params, &createRefundResponse)
-	if err != nil {
-		return
-	}
-	if createRefundResponse.ErrNo != 0 {
-		return createRefundResponse, fmt.Errorf("CreateRefund error %s %d", createRefundResponse.ErrTips, createRefundResponse.ErrNo)
-	}
+	err = d.postEndpoint(ctx, createRefund, params, &createRefundResponse)
 	return
 }
 
@@ -369,19 +488,18 @@ type QueryRefundParamsResponse struct {
 
 // QueryRefund 退款结果查询
 func (d *DouYinOpenApi) QueryRefund(outRefundNo, thirdpartyId string) (queryRefundParamsResponse QueryRefundParamsResponse, err error) {
+	return d.QueryRefundCtx(context.Background(), outRefundNo, thirdpartyId)
+}
+
+// QueryRefundCtx 退款结果查询 支持传入context
+func (d *DouYinOpenApi) QueryRefundCtx(ctx context.Context, outRefundNo, thirdpartyId string) (queryRefundParamsResponse QueryRefundParamsResponse, err error) {
 	params := QueryRefundParams{
 		OutRefundNo:  outRefundNo,
 		AppId:        d.Config.AppId,
 		ThirdpartyId: thirdpartyId,
 	}
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(queryRefund, params, &queryRefundParamsResponse)
-	if err != nil {
-		return
-	}
-	if queryRefundParamsResponse.ErrNo != 0 {
-		return queryRefundParamsResponse, fmt.Errorf("QueryRefund error %s %d", queryRefundParamsResponse.ErrTips, queryRefundParamsResponse.ErrNo)
-	}
+	err = d.postEndpoint(ctx, queryRefund, params, &queryRefundParamsResponse)
 	return
 }
 
@@ -410,6 +528,9 @@ type RefundCallbackResponseMsg struct {
 
 // RefundCallback 退款结果回调
 func (d *DouYinOpenApi) RefundCallback(body string, checkSign bool) (refundCallbackResponse RefundCallbackResponse, err error) {
+	defer func() {
+		d.logCallback("RefundCallback", refundCallbackResponse.Type, refundCallbackResponse.Msg, err)
+	}()
 	err = json.Unmarshal([]byte(body), &refundCallbackResponse)
 	if err != nil {
 		return
@@ -465,18 +586,16 @@ type SettleResponse struct {
 
 // Settle 发起结算及分账
 func (d *DouYinOpenApi) Settle(settleParams SettleParams, settleParamsItem ...SettleParamsItem) (settleResponse SettleResponse, err error) {
+	return d.SettleCtx(context.Background(), settleParams, settleParamsItem...)
+}
+
+// SettleCtx 发起结算及分账 支持传入context
+func (d *DouYinOpenApi) SettleCtx(ctx context.Context, settleParams SettleParams, settleParamsItem ...SettleParamsItem) (settleResponse SettleResponse, err error) {
 	settleParams.AppId = d.Config.AppId
 	settleItem, _ := json.Marshal(settleParamsItem)
 	settleParams.SettleParams = string(settleItem)
 	settleParams.Sign = d.GenerateSign(settleParams)
-	err = d.PostJson(settle, settleParams, &settleResponse)
-	if err != nil {
-		return
-	}
-	if settleResponse.ErrNo != 0 {
-		err = fmt.Errorf("settle error %s %d", settleResponse.ErrTips, settleResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, settle, settleParams, &settleResponse)
 	return
 }
 
@@ -507,20 +626,18 @@ type QuerySettleResponse struct {
 
 // QuerySettle 结算结果查询 querySettle
 func (d *DouYinOpenApi) QuerySettle(outSettleNo, thirdpartyId string) (querySettleResponse QuerySettleResponse, err error) {
+	return d.QuerySettleCtx(context.Background(), outSettleNo, thirdpartyId)
+}
+
+// QuerySettleCtx 结算结果查询 支持传入context
+func (d *DouYinOpenApi) QuerySettleCtx(ctx context.Context, outSettleNo, thirdpartyId string) (querySettleResponse QuerySettleResponse, err error) {
 	params := QuerySettleParams{
 		AppId:        d.Config.AppId,
 		OutSettleNo:  outSettleNo,
 		ThirdpartyId: thirdpartyId,
 	}
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(querySettle, params, &querySettleResponse)
-	if err != nil {
-		return
-	}
-	if querySettleResponse.ErrNo != 0 {
-		err = fmt.Errorf("QuerySettle error %s %d", querySettleResponse.ErrTips, querySettleResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, querySettle, params, &querySettleResponse)
 	return
 }
 
@@ -553,6 +670,9 @@ type SettleCallbackResponseMsg struct {
 
 // SettleCallback 结算结果回调
 func (d *DouYinOpenApi) SettleCallback(body string, checkSign bool) (settleCallbackResponse SettleCallbackResponse, err error) {
+	defer func() {
+		d.logCallback("SettleCallback", settleCallbackResponse.Type, settleCallbackResponse.Msg, err)
+	}()
 	err = json.Unmarshal([]byte(body), &settleCallbackResponse)
 	if err != nil {
 		return
@@ -612,6 +732,11 @@ type UnsettleAmountResponse struct {
 
 // UnsettleAmount 可分账余额查询 unsettleAmount
 func (d *DouYinOpenApi) UnsettleAmount(outOrderNo, thirdpartyId, outItemOrderNo string) (unsettleAmountResponse UnsettleAmountResponse, err error) {
+	return d.UnsettleAmountCtx(context.Background(), outOrderNo, thirdpartyId, outItemOrderNo)
+}
+
+// UnsettleAmountCtx 可分账余额查询 支持传入context
+func (d *DouYinOpenApi) UnsettleAmountCtx(ctx context.Context, outOrderNo, thirdpartyId, outItemOrderNo string) (unsettleAmountResponse UnsettleAmountResponse, err error) {
 	params := UnsettleAmountParams{
 		OutOrderNo:     outOrderNo,
 		AppId:          d.Config.AppId,
@@ -619,14 +744,7 @@ func (d *DouYinOpenApi) UnsettleAmount(outOrderNo, thirdpartyId, outItemOrderNo
 		OutItemOrderNo: outItemOrderNo,
 	}
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(unsettleAmount, params, &unsettleAmountResponse)
-	if err != nil {
-		return
-	}
-	if unsettleAmountResponse.ErrNo != 0 {
-		err = fmt.Errorf("UnsettleAmount error %s %d", unsettleAmountResponse.ErrTips, unsettleAmountResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, unsettleAmount, params, &unsettleAmountResponse)
 	return
 }
 
@@ -665,16 +783,14 @@ type CreateReturnResponse struct {
 
 // CreateReturn 退分账 createReturn
 func (d *DouYinOpenApi) CreateReturn(params CreateReturnParams) (createReturnResponse CreateReturnResponse, err error) {
+	return d.CreateReturnCtx(context.Background(), params)
+}
+
+// CreateReturnCtx 退分账 支持传入context
+func (d *DouYinOpenApi) CreateReturnCtx(ctx context.Context, params CreateReturnParams) (createReturnResponse CreateReturnResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(createReturn, params, &createReturnResponse)
-	if err != nil {
-		return
-	}
-	if createReturnResponse.ErrNo != 0 {
-		err = fmt.Errorf("CreateReturn error %s %d", createReturnResponse.ErrTips, createReturnResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, createReturn, params, &createReturnResponse)
 	return
 }
 
@@ -709,6 +825,11 @@ type QueryReturnResponse struct {
 
 // QueryReturn 退分账结果查询 queryReturn
 func (d *DouYinOpenApi) QueryReturn(returnNo, outReturnNo, thirdpartyId string) (queryReturnResponse QueryReturnResponse, err error) {
+	return d.QueryReturnCtx(context.Background(), returnNo, outReturnNo, thirdpartyId)
+}
+
+// QueryReturnCtx 退分账结果查询 支持传入context
+func (d *DouYinOpenApi) QueryReturnCtx(ctx context.Context, returnNo, outReturnNo, thirdpartyId string) (queryReturnResponse QueryReturnResponse, err error) {
 	params := QueryReturnParams{
 		AppId:        d.Config.AppId,
 		ReturnNo:     returnNo,
@@ -716,14 +837,7 @@ func (d *DouYinOpenApi) QueryReturn(returnNo, outReturnNo, thirdpartyId string)
 		ThirdpartyId: thirdpartyId,
 	}
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(queryReturn, params, &queryReturnResponse)
-	if err != nil {
-		return
-	}
-	if queryReturnResponse.ErrNo != 0 {
-		err = fmt.Errorf("queryReturnResponse error %s %d", queryReturnResponse.ErrTips, queryReturnResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, queryReturn, params, &queryReturnResponse)
 	return
 }
 
@@ -756,16 +870,14 @@ type QueryMerchantBalanceResponse struct {
 
 // QueryMerchantBalance 可提现余额查询
 func (d *DouYinOpenApi) QueryMerchantBalance(params QueryMerchantBalanceParams) (queryMerchantBalanceResponse QueryMerchantBalanceResponse, err error) {
+	return d.QueryMerchantBalanceCtx(context.Background(), params)
+}
+
+// QueryMerchantBalanceCtx 可提现余额查询 支持传入context
+func (d *DouYinOpenApi) QueryMerchantBalanceCtx(ctx context.Context, params QueryMerchantBalanceParams) (queryMerchantBalanceResponse QueryMerchantBalanceResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(queryMerchantBalance, params, &queryMerchantBalanceResponse)
-	if err != nil {
-		return
-	}
-	if queryMerchantBalanceResponse.ErrNo != 0 {
-		err = fmt.Errorf("queryMerchantBalanceResponse error %s %d", queryMerchantBalanceResponse.ErrTips, queryMerchantBalanceResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, queryMerchantBalance, params, &queryMerchantBalanceResponse)
 	return
 }
 
@@ -792,16 +904,14 @@ type MerchantWithdrawResponse struct {
 
 // MerchantWithdraw 提现
 func (d *DouYinOpenApi) MerchantWithdraw(params MerchantWithdrawParams) (merchantWithdrawResponse MerchantWithdrawResponse, err error) {
+	return d.MerchantWithdrawCtx(context.Background(), params)
+}
+
+// MerchantWithdrawCtx 提现 支持传入context
+func (d *DouYinOpenApi) MerchantWithdrawCtx(ctx context.Context, params MerchantWithdrawParams) (merchantWithdrawResponse MerchantWithdrawResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(merchantWithdraw, params, &merchantWithdrawResponse)
-	if err != nil {
-		return
-	}
-	if merchantWithdrawResponse.ErrNo != 0 {
-		err = fmt.Errorf("merchantWithdrawResponse error %s %d", merchantWithdrawResponse.ErrTips, merchantWithdrawResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, merchantWithdraw, params, &merchantWithdrawResponse)
 	return
 }
 
@@ -824,16 +934,14 @@ type QueryWithdrawOrderResponse struct {
 
 // QueryWithdrawOrder 提现结果查询
 func (d *DouYinOpenApi) QueryWithdrawOrder(params QueryWithdrawOrderParams) (queryWithdrawOrderResponse QueryWithdrawOrderResponse, err error) {
+	return d.QueryWithdrawOrderCtx(context.Background(), params)
+}
+
+// QueryWithdrawOrderCtx 提现结果查询 支持传入context
+func (d *DouYinOpenApi) QueryWithdrawOrderCtx(ctx context.Context, params QueryWithdrawOrderParams) (queryWithdrawOrderResponse QueryWithdrawOrderResponse, err error) {
 	params.AppId = d.Config.AppId
 	params.Sign = d.GenerateSign(params)
-	err = d.PostJson(queryWithdrawOrder, params, &queryWithdrawOrderResponse)
-	if err != nil {
-		return
-	}
-	if queryWithdrawOrderResponse.ErrNo != 0 {
-		err = fmt.Errorf("queryWithdrawOrderResponse error %s %d", queryWithdrawOrderResponse.ErrTips, queryWithdrawOrderResponse.ErrNo)
-		return
-	}
+	err = d.postEndpoint(ctx, queryWithdrawOrder, params, &queryWithdrawOrderResponse)
 	return
 }
 
@@ -859,6 +967,9 @@ type MerchantWithdrawCallbackResponseMsg struct {
 
 // MerchantWithdrawCallback 提现回调
 func (d *DouYinOpenApi) MerchantWithdrawCallback(body string, checkSign bool) (merchantWithdrawCallbackResponse MerchantWithdrawCallbackResponse, err error) {
+	defer func() {
+		d.logCallback("MerchantWithdrawCallback", merchantWithdrawCallbackResponse.Type, merchantWithdrawCallbackResponse.Msg, err)
+	}()
 	err = json.Unmarshal([]byte(body), &merchantWithdrawCallbackResponse)
 	if err != nil {
 		return
@@ -888,7 +999,7 @@ func (d *DouYinOpenApi) MerchantWithdrawCallback(body string, checkSign bool) (m
 // OrderV2PushParams 订单推送
 type OrderV2PushParams struct {
 	ClientKey   string `json:"client_key,omitempty"`   // 否 第三方在抖音开放平台申请的 ClientKey 注意：POI 订单必传 awx1334dlkfjdf
-	AccessToken string `json:"access_token,omitempty"` // 是 服务端 API 调用标识，通过 getAccessToken 获取
+	AccessToken string `json:"access_token,omitempty"` // 是 服务端 API 调用标识，通过 getAccessToken 获取 为空时自动使用 Config.AccessToken 获取
 	ExtShopId   string `json:"ext_shop_id,omitempty"`  // 否 POI 店铺同步时使用的开发者侧店铺 ID，购买店铺 ID，长度 < 256 byte 注意：POI 订单必传 ext_112233
 	AppName     string `json:"app_name,omitempty"`     // 是 做订单展示的字节系 app 名称，目前为固定值“douyin” douyin
 	OpenId      string `json:"open_id,omitempty"`      // 是 小程序用户的 open_id，通过 code2Session 获取 d33432323423
@@ -940,15 +1051,13 @@ type OrderV2PushResponse struct {
 
 // OrderV2Push 订单推送
 func (d *DouYinOpenApi) OrderV2Push(normal OrderV2PushParams) (orderV2PushResponse OrderV2PushResponse, err error) {
+	return d.OrderV2PushCtx(context.Background(), normal)
+}
+
+// OrderV2PushCtx 订单推送 支持传入context
+func (d *DouYinOpenApi) OrderV2PushCtx(ctx context.Context, normal OrderV2PushParams) (orderV2PushResponse OrderV2PushResponse, err error) {
 	// normal.AppId = d.Config.AppId
 	// normal.Sign = d.GenerateSign(params)
-	err = d.PostJson(orderV2Push, normal, &orderV2PushResponse)
-	if err != nil {
-		return
-	}
-	if orderV2PushResponse.ErrCode != 0 {
-		err = fmt.Errorf("OrderV2Push error %s %s", orderV2PushResponse.ErrMsg, orderV2PushResponse.Body)
-		return
-	}
+	err = d.postEndpoint(ctx, orderV2Push, normal, &orderV2PushResponse)
 	return
 }
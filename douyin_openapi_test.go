@@ -1,11 +1,26 @@
 package douyin_openapi
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	accessToken "github.com/HeartGarlic/douyin-openapi/access-token"
 	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -104,7 +119,7 @@ func TestDouYinOpenApi_CreateOrder(t *testing.T) {
 		ValidTime:       300,
 		CpExtra:         "123",
 		NotifyUrl:       NotifyUrl,
-		ExpandOrderInfo: ExpandOrderInfo{},
+		ExpandOrderInfo: &ExpandOrderInfo{},
 	}
 	res, err := OpenApi.CreateOrder(params)
 	if err != nil {
@@ -187,3 +202,443 @@ func TestDouYinOpenApi_OrderV2Push(t *testing.T) {
 	res, err := OpenApi.OrderV2Push(normal)
 	fmt.Printf("res: %+v err: %+v", res, err)
 }
+
+// mockDoer 记录请求并返回固定结果的http客户端
+type mockDoer struct {
+	requests []*http.Request
+	body     string
+}
+
+func (m *mockDoer) Do(req *http.Request) (*http.Response, error) {
+	m.requests = append(m.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(m.body)),
+		Header:     http.Header{},
+	}, nil
+}
+
+// 测试自定义http客户端
+func TestDouYinOpenApi_HttpClient(t *testing.T) {
+	doer := &mockDoer{body: `{"err_no":0,"data":{"openid":"open_id"}}`}
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app", HttpClient: doer})
+	session, err := api.Code2Session("code", "")
+	if err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	if session.Data.Openid != "open_id" || len(doer.requests) != 1 {
+		t.Fatalf("got a value %+v requests %d", session, len(doer.requests))
+	}
+	if token := api.Config.AccessToken.(*accessToken.DefaultAccessToken); token.HttpClient != doer {
+		t.Fatalf("access token not use custom http client")
+	}
+}
+
+// 测试context取消后请求不会发出
+func TestDouYinOpenApi_QueryOrderCtx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should not be sent")
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app"})
+	err := api.PostJsonCtx(ctx, server.URL, QueryOrderParams{}, &QueryOrderResponse{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got a error %v", err)
+	}
+}
+
+// 测试接口错误的解析和分类
+func TestDouYinOpenApi_APIError(t *testing.T) {
+	doer := &mockDoer{body: `{"err_no":2008,"err_tips":"签名校验失败","log_id":"log_1"}`}
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app", HttpClient: doer})
+	_, err := api.QueryOrder("out_order_no", "")
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.ErrNo != 2008 || apiError.LogID != "log_1" || apiError.Endpoint != "/api/apps/ecpay/v1/query_order" {
+		t.Fatalf("got a error %#v", err)
+	}
+	if !errors.Is(err, ErrInvalidSign) || errors.Is(err, ErrSystemBusy) {
+		t.Fatalf("error class mismatch: %v", err)
+	}
+	doer.body = `{"err_code":40004,"err_msg":"access_token expired","body":""}`
+	_, err = api.OrderV2Push(OrderV2PushParams{})
+	if !errors.As(err, &apiError) || apiError.ErrNo != 40004 || !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got a error %#v", err)
+	}
+	// 登记过的错误码不依赖错误信息的措辞
+	if err = util.CheckResponse(queryOrder, []byte(`{"err_no":2008,"err_tips":"check failed"}`)); !errors.Is(err, ErrInvalidSign) {
+		t.Fatalf("got a error %v", err)
+	}
+	// 未登记的错误码只按完整短语分类
+	for tips, class := range map[string]error{
+		"Access_Token Expired":        ErrTokenExpired,
+		"签名错误":                        ErrInvalidSign,
+		"invalid sign: bad timestamp": ErrInvalidSign,
+		"order not exist":             ErrOrderNotFound,
+		"余额不足":                        ErrInsufficientBalance,
+		"access_token is required":    nil,
+		"design mismatch":             nil,
+		"assign failed":               nil,
+		"resign error":                nil,
+		"app not exist":               nil,
+		"merchant not exist":          nil,
+		"sub_order not found, retry":  nil,
+	} {
+		apiError = &APIError{ErrNo: 99999, ErrTips: tips}
+		if got := apiError.Class(); got != class {
+			t.Fatalf("%q got a class %v want %v", tips, got, class)
+		}
+	}
+	if err = util.CheckResponse(queryOrder, []byte(`<html>502 Bad Gateway</html>`)); err == nil || errors.As(err, &apiError) {
+		t.Fatalf("invalid json should return a parse error: %v", err)
+	}
+}
+
+// 测试接口地址的选择
+func TestDouYinOpenApi_GetApiUrl(t *testing.T) {
+	doer := &mockDoer{body: `{"err_no":0}`}
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app", IsSandbox: true, HttpClient: doer})
+	if _, err := api.CreateRefund(CreateRefundParams{OutRefundNo: "1"}); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	if got := doer.requests[0].URL.String(); got != sandboxBaseApi+createRefund {
+		t.Fatalf("got a url %s", got)
+	}
+	api = NewDouYinOpenApi(DouYinOpenApiConfig{
+		AppId:      "tt_app",
+		HttpClient: doer,
+		BaseApi:    "http://127.0.0.1:8080/",
+		Endpoints:  map[string]string{settle: "http://127.0.0.1:9090/settle"},
+	})
+	if got := api.GetApiUrl(queryOrder); got != "http://127.0.0.1:8080"+queryOrder {
+		t.Fatalf("got a url %s", got)
+	}
+	if got := api.GetApiUrl(settle); got != "http://127.0.0.1:9090/settle" {
+		t.Fatalf("got a url %s", got)
+	}
+	if got := api.Config.AccessToken.(*accessToken.DefaultAccessToken).ApiUrl; got != "http://127.0.0.1:8080"+accessToken.AccessTokenPath {
+		t.Fatalf("got a token url %s", got)
+	}
+}
+
+// 测试查询接口和幂等写接口的重试
+func TestDouYinOpenApi_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			_, _ = w.Write([]byte(`{"err_no":-1,"err_tips":"系统繁忙"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"err_no":0,"refund_no":"refund_no"}`))
+	}))
+	defer server.Close()
+	retry := NewRetryPolicy()
+	retry.InitialBackoff = time.Millisecond
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app", BaseApi: server.URL, Retry: retry})
+	if _, err := api.QueryOrder("out_order_no", ""); err != nil || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("got a error %v calls %d", err, atomic.LoadInt32(&calls))
+	}
+	// 写接口默认不重试
+	if _, err := api.CreateRefund(CreateRefundParams{OutRefundNo: "1"}); !errors.Is(err, ErrSystemBusy) || atomic.LoadInt32(&calls) != 4 {
+		t.Fatalf("got a error %v calls %d", err, atomic.LoadInt32(&calls))
+	}
+	retry.RetryWrites = true
+	res, err := api.CreateRefund(CreateRefundParams{OutRefundNo: "1"})
+	if err != nil || res.RefundNo != "refund_no" || res.ErrTips != "" || atomic.LoadInt32(&calls) != 6 {
+		t.Fatalf("got a error %v calls %d", err, atomic.LoadInt32(&calls))
+	}
+}
+
+// 测试拦截器可以修改请求和直接返回结果
+func TestDouYinOpenApi_Interceptors(t *testing.T) {
+	doer := &mockDoer{body: `{"err_no":0,"order_id":"order_id"}`}
+	var endpoints []string
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{
+		AppId:      "tt_app",
+		HttpClient: doer,
+		Interceptors: []Interceptor{
+			func(ctx context.Context, req *Request, invoker Invoker) ([]byte, error) {
+				endpoints = append(endpoints, req.Endpoint)
+				req.Header.Set("X-Trace-Id", "trace_id")
+				return invoker(ctx, req)
+			},
+			func(ctx context.Context, req *Request, invoker Invoker) ([]byte, error) {
+				if req.Endpoint == settle {
+					return []byte(`{"err_no":0,"settle_no":"mock_settle_no"}`), nil
+				}
+				return invoker(ctx, req)
+			},
+		},
+	})
+	res, err := api.QueryOrder("out_order_no", "")
+	if err != nil || res.OrderId != "order_id" || doer.requests[0].Header.Get("X-Trace-Id") != "trace_id" {
+		t.Fatalf("got a error %v value %+v", err, res)
+	}
+	settleRes, err := api.Settle(SettleParams{OutSettleNo: "1"})
+	if err != nil || settleRes.SettleNo != "mock_settle_no" || len(doer.requests) != 1 {
+		t.Fatalf("got a error %v value %+v", err, settleRes)
+	}
+	if len(endpoints) != 2 || endpoints[0] != queryOrder || endpoints[1] != settle {
+		t.Fatalf("got endpoints %v", endpoints)
+	}
+}
+
+// captureLogger 记录日志的输出
+type captureLogger struct {
+	lines []string
+}
+
+func (c *captureLogger) Log(ctx context.Context, level util.Level, msg string, fields ...util.Field) {
+	line := level.String() + " " + msg
+	for _, field := range fields {
+		line += fmt.Sprintf(" %s=%v", field.Key, field.Value)
+	}
+	c.lines = append(c.lines, line)
+}
+
+// 测试日志记录和敏感字段脱敏
+func TestDouYinOpenApi_Logger(t *testing.T) {
+	logger := &captureLogger{}
+	doer := &mockDoer{body: `{"err_no":0,"data":{"session_key":"session_key_value","openid":"open_id"}}`}
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_app", AppSecret: "secret_value", Salt: "salt_value", HttpClient: doer, Logger: logger})
+	if _, err := api.Code2Session("code", ""); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	if _, err := api.QueryOrder("out_order_no_1", ""); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	output := strings.Join(logger.lines, "\n")
+	for _, secret := range []string{"secret_value", "session_key_value", api.GenerateSign(QueryOrderParams{AppId: "tt_app", OutOrderNo: "out_order_no_1"})} {
+		if strings.Contains(output, secret) {
+			t.Fatalf("secret %s not redacted: %s", secret, output)
+		}
+	}
+	if !strings.Contains(output, "out_order_no=out_order_no_1") || !strings.Contains(output, "endpoint="+queryOrder) {
+		t.Fatalf("got a log %s", output)
+	}
+}
+
+// notifyCache 写入缓存时发出通知
+type notifyCache struct {
+	cache.Cache
+	sets chan string
+}
+
+func (n *notifyCache) Set(key string, val interface{}, timeout time.Duration) error {
+	err := n.Cache.Set(key, val, timeout)
+	n.sets <- key
+	return err
+}
+
+// 测试后台刷新token
+func TestDouyinOpenapi_Refresher(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = w.Write([]byte(`{"err_no":-1,"err_tips":"系统繁忙"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"token_value","expires_in":7200}}`))
+	}))
+	defer server.Close()
+	tokenCache := &notifyCache{Cache: cache.NewMemory(), sets: make(chan string, 1)}
+	token := accessToken.NewDefaultAccessToken("tt_refresher", AppSecret, tokenCache, false).(*accessToken.DefaultAccessToken)
+	token.ApiUrl = server.URL
+	errs := make(chan error, 1)
+	refresher := accessToken.NewRefresher(token, func(err error) {
+		errs <- err
+	})
+	refresher.RetryInterval = 10 * time.Millisecond
+	refresher.Start()
+	defer refresher.Stop()
+	if err := <-errs; !errors.Is(err, ErrSystemBusy) {
+		t.Fatalf("got a error %v", err)
+	}
+	<-tokenCache.sets
+	refresher.Stop()
+	if val := token.Cache.Get(token.GetCacheKey()); val != "token_value" {
+		t.Fatalf("got a value %v", val)
+	}
+}
+
+// 测试同一个app合并获取token 不同app互不阻塞
+func TestDouyinOpenapi_AccessTokenFlight(t *testing.T) {
+	release := make(chan struct{})
+	var slowCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		if params["appid"] == "tt_slow" {
+			atomic.AddInt32(&slowCalls, 1)
+			<-release
+		}
+		_, _ = fmt.Fprintf(w, `{"err_no":0,"data":{"access_token":"%s_token","expires_in":7200}}`, params["appid"])
+	}))
+	defer server.Close()
+	newToken := func(appId string) accessToken.AccessToken {
+		token := accessToken.NewDefaultAccessToken(appId, AppSecret, cache.NewMemory(), false).(*accessToken.DefaultAccessToken)
+		token.ApiUrl = server.URL
+		return token
+	}
+	slow := newToken("tt_slow")
+	results := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			val, _ := slow.GetAccessToken()
+			results <- val
+		}()
+	}
+	fast, err := newToken("tt_fast").GetAccessToken()
+	if err != nil || fast != "tt_fast_token" {
+		t.Fatalf("got a error %v value %s", err, fast)
+	}
+	close(release)
+	for i := 0; i < 10; i++ {
+		if val := <-results; val != "tt_slow_token" {
+			t.Fatalf("got a value %s", val)
+		}
+	}
+	if calls := atomic.LoadInt32(&slowCalls); calls != 1 {
+		t.Fatalf("got calls %d", calls)
+	}
+}
+
+// 测试token失效时刷新后重试
+func TestDouYinOpenApi_OrderV2PushTokenExpired(t *testing.T) {
+	var pushTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		if r.URL.Path == accessToken.AccessTokenPath {
+			_, _ = w.Write([]byte(`{"err_no":0,"data":{"access_token":"new_token","expires_in":7200}}`))
+			return
+		}
+		pushTokens = append(pushTokens, fmt.Sprint(params["access_token"]))
+		if params["access_token"] != "new_token" {
+			_, _ = w.Write([]byte(`{"err_code":40004,"err_msg":"access_token expired"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"err_code":0,"body":"ok"}`))
+	}))
+	defer server.Close()
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_push", BaseApi: server.URL})
+	_ = api.Config.Cache.Set(api.Config.AccessToken.GetCacheKey(), "old_token", time.Minute)
+	res, err := api.OrderV2Push(OrderV2PushParams{AccessToken: "old_token", OpenId: "open_id"})
+	if err != nil || res.Body != "ok" || res.ErrMsg != "" || strings.Join(pushTokens, ",") != "old_token,new_token" {
+		t.Fatalf("got a error %v value %+v tokens %v", err, res, pushTokens)
+	}
+}
+
+// 测试自动注入 access_token
+func TestDouYinOpenApi_TokenInjection(t *testing.T) {
+	doer := &mockDoer{body: `{"err_no":0,"err_code":0}`}
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_inject", HttpClient: doer})
+	_ = api.Config.Cache.Set(api.Config.AccessToken.GetCacheKey(), "cached_token", time.Minute)
+	if _, err := api.OrderV2Push(OrderV2PushParams{OpenId: "open_id", OrderType: 0}); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	var params map[string]interface{}
+	_ = json.NewDecoder(doer.requests[0].Body).Decode(&params)
+	if params["access_token"] != "cached_token" || params["open_id"] != "open_id" {
+		t.Fatalf("got params %v", params)
+	}
+	if err := api.PostJsonWithToken(api.GetApiUrl("/api/apps/new_api"), TokenInHeader, map[string]string{"a": "b"}, &QueryOrderResponse{}); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	if got := doer.requests[1].Header.Get("access-token"); got != "cached_token" {
+		t.Fatalf("got header %s", got)
+	}
+	if err := api.PostJsonWithToken(api.GetApiUrl("/api/apps/new_api"), TokenInBody, nil, &QueryOrderResponse{}); err != nil {
+		t.Fatalf("got a error %s", err.Error())
+	}
+	params = nil
+	_ = json.NewDecoder(doer.requests[2].Body).Decode(&params)
+	if params["access_token"] != "cached_token" {
+		t.Fatalf("got params %v", params)
+	}
+	if err := api.PostJsonWithToken(api.GetApiUrl("/api/apps/new_api"), 0, map[string]string{"a": "b"}, &QueryOrderResponse{}); err == nil || len(doer.requests) != 3 {
+		t.Fatalf("unknown placement should fail without a request: %v", err)
+	}
+}
+
+// 测试通用交易系统的 RSA 签名和返回值验签
+func TestDouYinOpenApi_RSASigner(t *testing.T) {
+	appKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	platformKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	appKeyDer, _ := x509.MarshalPKCS8PrivateKey(appKey)
+	appKeyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: appKeyDer})
+	platformPublicPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&platformKey.PublicKey)})
+	signer, err := util.NewRSASigner("tt_rsa", "1", appKeyPem, platformPublicPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		authorization := r.Header.Get(util.AuthorizationHeader)
+		if r.URL.Path == createOrder || r.URL.Path == "/api/apps/qrcode" {
+			if authorization != "" {
+				t.Errorf("%s should not be rsa signed", r.URL.Path)
+			}
+			// 未签名的接口返回值没有签名请求头
+			_, _ = w.Write([]byte(`{"err_no":0}`))
+			return
+		}
+		fields := map[string]string{}
+		for _, pair := range strings.Split(strings.TrimPrefix(authorization, "SHA256-RSA2048 "), ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			fields[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+		signature, _ := base64.StdEncoding.DecodeString(fields["signature"])
+		hashed := sha256.Sum256([]byte("POST\n" + r.URL.RequestURI() + "\n" + fields["timestamp"] + "\n" + fields["nonce_str"] + "\n" + string(body) + "\n"))
+		if fields["appid"] != "tt_rsa" || fields["key_version"] != "1" || rsa.VerifyPKCS1v15(&appKey.PublicKey, crypto.SHA256, hashed[:], signature) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		response := []byte(`{"err_no":0,"err_tips":"success"}`)
+		if strings.Contains(string(body), "missing") {
+			// 交易系统的业务错误使用 err_no 和 err_msg
+			response = []byte(`{"err_no":11001,"err_msg":"order not exist","log_id":"log_rsa"}`)
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		hashed = sha256.Sum256([]byte(timestamp + "\nnonce\n" + string(response) + "\n"))
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, platformKey, crypto.SHA256, hashed[:])
+		w.Header().Set(util.TimestampHeader, timestamp)
+		w.Header().Set(util.NonceHeader, "nonce")
+		w.Header().Set(util.SignatureHeader, base64.StdEncoding.EncodeToString(signature))
+		if tamper {
+			response = []byte(`{"err_no":0,"err_tips":"tampered"}`)
+		}
+		_, _ = w.Write(response)
+	}))
+	defer server.Close()
+
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt_rsa", BaseApi: server.URL, Signer: signer})
+	var response QueryOrderResponse
+	if err = api.PostJson(api.GetApiUrl("/api/trade_basic/v1/developer/order_query?debug=1"), map[string]string{"order_id": "1"}, &response); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	if _, err = api.CreateOrder(CreateOrderParams{OutOrderNo: "legacy"}); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	// 配置了平台公钥时 非交易系统的接口仍然不签名也不验签
+	if err = api.PostJson(api.GetApiUrl("/api/apps/qrcode"), map[string]string{"appname": "douyin"}, &response); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	// 通过 SignedApis 指定的接口使用签名
+	api.Config.SignedApis = map[string]bool{"/api/apps/trade/v2/query": true}
+	if err = api.PostJson(api.GetApiUrl("/api/apps/trade/v2/query"), map[string]string{"order_id": "1"}, &response); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	var apiError *APIError
+	err = api.PostJson(api.GetApiUrl("/api/trade_basic/v1/developer/order_query"), map[string]string{"order_id": "missing"}, &response)
+	if !errors.As(err, &apiError) || apiError.ErrNo != 11001 || apiError.ErrTips != "order not exist" || apiError.LogID != "log_rsa" || !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("got a error %#v", err)
+	}
+	tamper = true
+	if err = api.PostJson(api.GetApiUrl("/api/trade_basic/v1/developer/order_query"), map[string]string{"order_id": "1"}, &response); err == nil || !strings.Contains(err.Error(), "verify") {
+		t.Fatalf("tampered response should fail verification: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package douyin_openapi
+
+import "github.com/HeartGarlic/douyin-openapi/util"
+
+// APIError 抖音接口返回的业务错误 可通过 errors.As 获取
+type APIError = util.APIError
+
+// 错误分类 可以通过 errors.Is 判断
+var (
+	ErrInvalidSign         = util.ErrInvalidSign         // 签名错误
+	ErrTokenExpired        = util.ErrTokenExpired        // access_token 过期或无效
+	ErrOrderNotFound       = util.ErrOrderNotFound       // 订单不存在
+	ErrInsufficientBalance = util.ErrInsufficientBalance // 余额不足
+	ErrRateLimited         = util.ErrRateLimited         // 请求频率超限
+	ErrSystemBusy          = util.ErrSystemBusy          // 系统繁忙
+)
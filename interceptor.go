@@ -0,0 +1,13 @@
+package douyin_openapi
+
+import "github.com/HeartGarlic/douyin-openapi/util"
+
+// Request 一次接口请求 包含接口名称 请求地址 已签名的参数和请求头
+type Request = util.Request
+
+// Invoker 执行请求并返回原始的返回值
+type Invoker = util.Invoker
+
+// Interceptor 请求拦截器 可用于日志 监控 链路追踪 注入请求头和故障注入
+// 拦截器可以修改请求 也可以不调用 invoker 直接返回结果
+type Interceptor = util.Interceptor
@@ -0,0 +1,250 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	openApiURL        = "https://open.douyin.com"         // 开放平台正式地址
+	openSandboxApiURL = "https://open-sandbox.douyin.com" // 开放平台沙盒地址
+)
+
+const (
+	authorizePath         = "/platform/oauth/connect/"    // 用户授权页
+	accessTokenPath       = "/oauth/access_token/"        // 获取 access_token
+	refreshTokenPath      = "/oauth/refresh_token/"       // 刷新 access_token
+	renewRefreshTokenPath = "/oauth/renew_refresh_token/" // 刷新 refresh_token
+)
+
+// ErrNeedAuthorize 用户未授权或 refresh_token 已过期 需要重新引导用户授权
+var ErrNeedAuthorize = errors.New("douyin: user need authorize")
+
+// Config 用户授权配置
+type Config struct {
+	ClientKey    string             // 应用唯一标识
+	ClientSecret string             // 应用密钥
+	RedirectUri  string             // 授权成功后的回调地址
+	Cache        cache.Cache        // 缓存组件
+	Persister    Persister          // token持久化存储 可为空
+	SandBox      bool               // 是否沙盒地址
+	BaseApi      string             // 自定义接口地址 为空时根据 SandBox 选择
+	HttpClient   util.Doer          // http客户端 为空时使用默认客户端
+	Interceptors []util.Interceptor // 请求拦截器
+	Logger       util.Logger        // 日志 为空时不输出日志
+	RefreshAhead time.Duration      // access_token 过期前多久刷新 默认5分钟
+	RenewAhead   time.Duration      // refresh_token 过期前多久自动续期 为0时不自动续期 需要应用拥有 renew_refresh_token 权限
+}
+
+// OAuth 用户授权 access_token 的获取 刷新和续期
+type OAuth struct {
+	Config Config
+	Store  *Store
+	flight util.FlightGroup
+}
+
+// NewOAuth 实例化用户授权
+func NewOAuth(config Config) *OAuth {
+	if config.Cache == nil {
+		config.Cache = cache.NewMemory()
+	}
+	if config.RefreshAhead <= 0 {
+		config.RefreshAhead = 5 * time.Minute
+	}
+	return &OAuth{
+		Config: config,
+		Store:  NewStore(config.Cache, config.Persister, config.ClientKey),
+	}
+}
+
+// apiUrl 获取接口地址
+func (o *OAuth) apiUrl(path string) string {
+	if o.Config.BaseApi != "" {
+		return strings.TrimRight(o.Config.BaseApi, "/") + path
+	}
+	if o.Config.SandBox {
+		return openSandboxApiURL + path
+	}
+	return openApiURL + path
+}
+
+// AuthorizeURL 生成用户授权页地址 state 会在回调时原样返回 用于防止 csrf
+func (o *OAuth) AuthorizeURL(scopes []string, state string) string {
+	query := url.Values{}
+	query.Set("client_key", o.Config.ClientKey)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(scopes, ","))
+	query.Set("redirect_uri", o.Config.RedirectUri)
+	if state != "" {
+		query.Set("state", state)
+	}
+	return o.apiUrl(authorizePath) + "?" + query.Encode()
+}
+
+// ResOAuthToken 授权接口的返回结构体
+type ResOAuthToken struct {
+	Data    ResOAuthTokenData `json:"data"`
+	Message string            `json:"message"`
+	Extra   struct {
+		Logid string `json:"logid"`
+	} `json:"extra"`
+}
+
+type ResOAuthTokenData struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	OpenId           string `json:"open_id"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Scope            string `json:"scope"`
+	Description      string `json:"description"`
+	ErrorCode        int    `json:"error_code"`
+}
+
+// ExchangeCode 使用授权码换取 access_token 和 refresh_token 并保存
+func (o *OAuth) ExchangeCode(ctx context.Context, code string) (*UserToken, error) {
+	form := url.Values{}
+	form.Set("client_key", o.Config.ClientKey)
+	form.Set("client_secret", o.Config.ClientSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	res, err := o.post(ctx, accessTokenPath, form)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token := &UserToken{
+		OpenId:           res.Data.OpenId,
+		Scope:            res.Data.Scope,
+		AccessToken:      res.Data.AccessToken,
+		ExpiresAt:        now.Add(time.Duration(res.Data.ExpiresIn) * time.Second),
+		RefreshToken:     res.Data.RefreshToken,
+		RefreshExpiresAt: now.Add(time.Duration(res.Data.RefreshExpiresIn) * time.Second),
+	}
+	return token, o.Store.Save(ctx, token)
+}
+
+// RefreshToken 使用 refresh_token 刷新用户的 access_token
+func (o *OAuth) RefreshToken(ctx context.Context, openId string) (*UserToken, error) {
+	token, err := o.storedToken(ctx, openId)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("client_key", o.Config.ClientKey)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", token.RefreshToken)
+	res, err := o.post(ctx, refreshTokenPath, form)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token.AccessToken = res.Data.AccessToken
+	token.ExpiresAt = now.Add(time.Duration(res.Data.ExpiresIn) * time.Second)
+	if res.Data.Scope != "" {
+		token.Scope = res.Data.Scope
+	}
+	if res.Data.RefreshToken != "" {
+		token.RefreshToken = res.Data.RefreshToken
+		token.RefreshExpiresAt = now.Add(time.Duration(res.Data.RefreshExpiresIn) * time.Second)
+	}
+	return token, o.Store.Save(ctx, token)
+}
+
+// RenewRefreshToken 在 refresh_token 过期前续期 获取新的 refresh_token
+func (o *OAuth) RenewRefreshToken(ctx context.Context, openId string) (*UserToken, error) {
+	token, err := o.storedToken(ctx, openId)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("client_key", o.Config.ClientKey)
+	form.Set("refresh_token", token.RefreshToken)
+	res, err := o.post(ctx, renewRefreshTokenPath, form)
+	if err != nil {
+		return nil, err
+	}
+	token.RefreshToken = res.Data.RefreshToken
+	token.RefreshExpiresAt = time.Now().Add(time.Duration(res.Data.ExpiresIn) * time.Second)
+	return token, o.Store.Save(ctx, token)
+}
+
+// GetAccessToken 获取用户的 access_token 即将过期时自动刷新 同一个用户的并发刷新只会请求一次
+func (o *OAuth) GetAccessToken(ctx context.Context, openId string) (string, error) {
+	token, err := o.storedToken(ctx, openId)
+	if err != nil {
+		return "", err
+	}
+	if time.Until(token.ExpiresAt) > o.Config.RefreshAhead && !o.needRenew(token) {
+		return token.AccessToken, nil
+	}
+	return o.flight.Do(ctx, openId, func(ctx context.Context) (string, error) {
+		token, err := o.storedToken(ctx, openId)
+		if err != nil {
+			return "", err
+		}
+		if o.needRenew(token) {
+			if token, err = o.RenewRefreshToken(ctx, openId); err != nil {
+				return "", err
+			}
+		}
+		if time.Until(token.ExpiresAt) > o.Config.RefreshAhead {
+			return token.AccessToken, nil
+		}
+		if token, err = o.RefreshToken(ctx, openId); err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	})
+}
+
+// needRenew 判断 refresh_token 是否需要续期
+func (o *OAuth) needRenew(token *UserToken) bool {
+	return o.Config.RenewAhead > 0 && time.Until(token.RefreshExpiresAt) <= o.Config.RenewAhead
+}
+
+// storedToken 获取已保存且 refresh_token 未过期的用户token
+func (o *OAuth) storedToken(ctx context.Context, openId string) (*UserToken, error) {
+	token, err := o.Store.Get(ctx, openId)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || !token.RefreshExpiresAt.After(time.Now()) {
+		return nil, ErrNeedAuthorize
+	}
+	return token, nil
+}
+
+// post 请求授权接口
+func (o *OAuth) post(ctx context.Context, path string, form url.Values) (res ResOAuthToken, err error) {
+	invoker := util.ChainInterceptors(util.NewInvoker(o.Config.HttpClient), o.interceptors()...)
+	body, err := invoker(ctx, &util.Request{Endpoint: path, Url: o.apiUrl(path), Form: form, Header: http.Header{}})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return
+	}
+	if res.Data.ErrorCode != 0 {
+		err = &util.APIError{Endpoint: path, ErrNo: res.Data.ErrorCode, ErrTips: res.Data.Description, LogID: res.Extra.Logid, RawBody: body}
+	}
+	return
+}
+
+// interceptors 获取请求拦截器 配置了日志时在最内层记录请求日志
+func (o *OAuth) interceptors() []util.Interceptor {
+	if o.Config.Logger == nil {
+		return o.Config.Interceptors
+	}
+	interceptors := make([]util.Interceptor, 0, len(o.Config.Interceptors)+1)
+	interceptors = append(interceptors, o.Config.Interceptors...)
+	return append(interceptors, util.LogInterceptor(o.Config.Logger))
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryPersister 内存中的持久化存储
+type memoryPersister struct {
+	lock   sync.Mutex
+	tokens map[string]UserToken
+}
+
+func (m *memoryPersister) Load(ctx context.Context, openId string) (*UserToken, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if token, ok := m.tokens[openId]; ok {
+		return &token, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryPersister) Save(ctx context.Context, token *UserToken) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tokens[token.OpenId] = *token
+	return nil
+}
+
+func (m *memoryPersister) Delete(ctx context.Context, openId string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.tokens, openId)
+	return nil
+}
+
+// 测试授权码换取token 过期刷新 以及重启后从持久化存储恢复
+func TestOAuth_Lifecycle(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case accessTokenPath:
+			if r.PostForm.Get("code") != "auth_code" || r.PostForm.Get("client_secret") != "client_secret" {
+				_, _ = w.Write([]byte(`{"data":{"error_code":10008,"description":"code error"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"access_token":"act.1","expires_in":60,"open_id":"open_id","refresh_expires_in":86400,"refresh_token":"rft.1","scope":"user_info","error_code":0}}`))
+		case refreshTokenPath:
+			_, _ = w.Write([]byte(`{"data":{"access_token":"act.2","expires_in":1296000,"open_id":"open_id","refresh_expires_in":86400,"refresh_token":"rft.2","scope":"user_info","error_code":0}}`))
+		}
+	}))
+	defer server.Close()
+	persister := &memoryPersister{tokens: map[string]UserToken{}}
+	config := Config{ClientKey: "client_key", ClientSecret: "client_secret", RedirectUri: "https://example.com/cb", BaseApi: server.URL, Persister: persister}
+	o := NewOAuth(config)
+
+	authorize, _ := url.Parse(o.AuthorizeURL([]string{"user_info", "video.list"}, "state_1"))
+	if authorize.Path != authorizePath || authorize.Query().Get("scope") != "user_info,video.list" || authorize.Query().Get("state") != "state_1" {
+		t.Fatalf("got a url %s", authorize)
+	}
+	ctx := context.Background()
+	if _, err := o.GetAccessToken(ctx, "open_id"); !errors.Is(err, ErrNeedAuthorize) {
+		t.Fatalf("got a error %v", err)
+	}
+	token, err := o.ExchangeCode(ctx, "auth_code")
+	if err != nil || token.OpenId != "open_id" || token.RefreshToken != "rft.1" {
+		t.Fatalf("got a error %v value %+v", err, token)
+	}
+	// access_token 剩余有效期小于 RefreshAhead 时自动刷新
+	val, err := o.GetAccessToken(ctx, "open_id")
+	if err != nil || val != "act.2" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	// 模拟重启 使用新的缓存从持久化存储恢复
+	config.Cache = cache.NewMemory()
+	val, err = NewOAuth(config).GetAccessToken(ctx, "open_id")
+	if err != nil || val != "act.2" {
+		t.Fatalf("got a error %v value %s", err, val)
+	}
+	if strings.Join(paths, ",") != accessTokenPath+","+refreshTokenPath {
+		t.Fatalf("got paths %v", paths)
+	}
+	if saved := persister.tokens["open_id"]; saved.RefreshToken != "rft.2" || time.Until(saved.ExpiresAt) < time.Hour {
+		t.Fatalf("got a saved token %+v", saved)
+	}
+}
@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/cache"
+	"time"
+)
+
+// UserToken 用户授权的token
+type UserToken struct {
+	OpenId           string    `json:"open_id"`
+	Scope            string    `json:"scope"`
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"` // access_token 过期时间
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"` // refresh_token 过期时间
+}
+
+// Persister token的持久化存储 例如数据库 用于服务重启后恢复长期有效的 refresh_token
+type Persister interface {
+	Load(ctx context.Context, openId string) (*UserToken, error) // 不存在时返回 nil, nil
+	Save(ctx context.Context, token *UserToken) error
+	Delete(ctx context.Context, openId string) error
+}
+
+// Store 按 openid 存储用户token 缓存优先 缓存未命中时从 Persister 加载
+type Store struct {
+	Cache     cache.Cache // 缓存组件
+	Persister Persister   // 持久化存储 可为空
+	KeyPrefix string      // 缓存key的前缀
+}
+
+// NewStore 实例化用户token存储 clientKey 用于区分不同应用的缓存key
+func NewStore(c cache.Cache, persister Persister, clientKey string) *Store {
+	if c == nil {
+		panic(any("cache is need"))
+	}
+	return &Store{
+		Cache:     c,
+		Persister: persister,
+		KeyPrefix: fmt.Sprintf("douyin_openapi_user_token_%s_", clientKey),
+	}
+}
+
+// cacheKey 用户token的缓存key
+func (s *Store) cacheKey(openId string) string {
+	return s.KeyPrefix + openId
+}
+
+// Get 获取用户token 不存在时返回 nil, nil
+func (s *Store) Get(ctx context.Context, openId string) (*UserToken, error) {
+	cached, ok, err := s.typed().Get(ctx, s.cacheKey(openId))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &cached, nil
+	}
+	if s.Persister == nil {
+		return nil, nil
+	}
+	token, err := s.Persister.Load(ctx, openId)
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if err = s.setCache(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Save 保存用户token 同时写入缓存和持久化存储
+func (s *Store) Save(ctx context.Context, token *UserToken) error {
+	if s.Persister != nil {
+		if err := s.Persister.Save(ctx, token); err != nil {
+			return err
+		}
+	}
+	return s.setCache(ctx, token)
+}
+
+// Delete 删除用户token
+func (s *Store) Delete(ctx context.Context, openId string) error {
+	if s.Persister != nil {
+		if err := s.Persister.Delete(ctx, openId); err != nil {
+			return err
+		}
+	}
+	return s.Cache.Delete(s.cacheKey(openId))
+}
+
+// typed 以json字符串读写缓存的用户token
+func (s *Store) typed() *cache.TypedCache[UserToken] {
+	return cache.NewTypedCache[UserToken](s.Cache, cache.JSONCodec[UserToken]{})
+}
+
+// setCache 写入缓存 有效期与 refresh_token 一致
+func (s *Store) setCache(ctx context.Context, token *UserToken) error {
+	timeout := time.Until(token.RefreshExpiresAt)
+	if timeout <= 0 {
+		return s.Cache.Delete(s.cacheKey(token.OpenId))
+	}
+	return s.typed().Set(ctx, s.cacheKey(token.OpenId), *token, timeout)
+}
@@ -0,0 +1,129 @@
+package douyin_openapi
+
+import (
+	"context"
+	"errors"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// readEndpoints 只读的查询接口 可以安全重试
+var readEndpoints = map[string]bool{
+	queryOrder:           true,
+	queryRefund:          true,
+	querySettle:          true,
+	unsettleAmount:       true,
+	queryReturn:          true,
+	queryMerchantBalance: true,
+	queryWithdrawOrder:   true,
+}
+
+// idempotentEndpoints 以开发者单号(out_refund_no/out_settle_no)保证幂等的写接口 开启 RetryWrites 后才会重试
+var idempotentEndpoints = map[string]bool{
+	createRefund: true,
+	settle:       true,
+}
+
+// RetryPolicy 重试策略 使用带抖动的指数退避
+type RetryPolicy struct {
+	MaxAttempts    int                  // 最大请求次数 包含第一次请求 小于等于1时不重试
+	InitialBackoff time.Duration        // 第一次重试前的等待时间 默认 100ms
+	MaxBackoff     time.Duration        // 最大等待时间 默认 2s
+	Multiplier     float64              // 每次重试等待时间的倍数 默认 2
+	Jitter         float64              // 等待时间随机抖动的比例 取值 [0,1]
+	RetryWrites    bool                 // 是否重试幂等的写接口 例如 CreateRefund Settle
+	Retryable      func(err error) bool // 自定义哪些错误可以重试 为空时使用 IsRetryable
+}
+
+// NewRetryPolicy 实例化一个默认的重试策略 最多请求3次
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// attempts 获取接口的最大请求次数
+func (p *RetryPolicy) attempts(endpoint string) int {
+	if p == nil || p.MaxAttempts <= 1 {
+		return 1
+	}
+	if readEndpoints[endpoint] || (p.RetryWrites && idempotentEndpoints[endpoint]) {
+		return p.MaxAttempts
+	}
+	return 1
+}
+
+// retryable 判断错误是否可以重试
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff 计算第 attempt 次请求失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait, maxWait, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if wait <= 0 {
+		wait = 100 * time.Millisecond
+	}
+	if maxWait <= 0 {
+		maxWait = 2 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	for i := 1; i < attempt && wait < maxWait; i++ {
+		wait = time.Duration(float64(wait) * multiplier)
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		wait = time.Duration(float64(wait) * (1 - jitter + 2*jitter*rand.Float64()))
+	}
+	return wait
+}
+
+// IsRetryable 判断错误是否为临时错误 网络错误 5xx 429 以及系统繁忙和限流的业务错误可以重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrSystemBusy) || errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var statusError *util.HttpStatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode >= http.StatusInternalServerError || statusError.StatusCode == http.StatusTooManyRequests
+	}
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sleepContext 等待一段时间 context 结束时提前返回
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package douyin_openapi
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// signExcludeKeys 不参与签名的参数
+var signExcludeKeys = map[string]bool{
+	"other_settle_params": true,
+	"app_id":              true,
+	"thirdparty_id":       true,
+	"sign":                true,
+	"salt":                true,
+	"token":               true,
+}
+
+// signPlain 生成待签名的字符串 与官方demo一致
+// 参数值去除首尾空格和包裹的双引号 跳过空值和 null 数字保留原始文本 嵌套对象使用紧凑的json 连同 salt 排序后用 & 拼接
+func signPlain(params interface{}, salt string) (string, error) {
+	j, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	var paramsMap map[string]json.RawMessage
+	if err = json.Unmarshal(j, &paramsMap); err != nil {
+		return "", err
+	}
+	paramsArr := make([]string, 0, len(paramsMap)+1)
+	for k, raw := range paramsMap {
+		if signExcludeKeys[k] {
+			continue
+		}
+		value, err := signValue(raw)
+		if err != nil {
+			return "", fmt.Errorf("sign %s: %w", k, err)
+		}
+		if value == "" {
+			continue
+		}
+		paramsArr = append(paramsArr, value)
+	}
+	paramsArr = append(paramsArr, salt)
+	sort.Strings(paramsArr)
+	return strings.Join(paramsArr, "&"), nil
+}
+
+// signValue 转换单个参数值 返回空字符串时跳过该参数
+func signValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var value string
+	switch raw[0] {
+	case '"':
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", err
+		}
+	case '{', '[':
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return "", err
+		}
+		value = buf.String()
+	default:
+		// 数字 布尔值和 null 使用原始文本
+		value = string(raw)
+	}
+	return trimSignValue(value), nil
+}
+
+// trimSignValue 去除首尾空格和包裹的双引号 空值和 null 返回空字符串
+func trimSignValue(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") && len(value) > 1 {
+		value = value[1 : len(value)-1]
+	}
+	value = strings.TrimSpace(value)
+	if value == "null" {
+		return ""
+	}
+	return value
+}
+
+// fieldsSign 使用 SignFields 计算签名 参数值包含非法的utf8时json会替换字符 返回 false 由json方式计算
+func fieldsSign(fielder SignFielder, salt string) (string, bool) {
+	var buf [16]string
+	fields := fielder.SignFields(buf[:0])
+	n, size := 0, len(salt)
+	for _, value := range fields {
+		value = trimSignValue(value)
+		if value == "" {
+			continue
+		}
+		if !utf8.ValidString(value) {
+			return "", false
+		}
+		fields[n] = value
+		n++
+		size += len(value) + 1
+	}
+	fields = append(fields[:n], salt)
+	sort.Strings(fields)
+	plain := make([]byte, 0, size)
+	for i, value := range fields {
+		if i > 0 {
+			plain = append(plain, '&')
+		}
+		plain = append(plain, value...)
+	}
+	sum := md5.Sum(plain)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// md5Sign 计算待签名字符串的md5
+func md5Sign(plain string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(plain)))
+}
@@ -0,0 +1,102 @@
+package douyin_openapi
+
+import (
+	"strconv"
+)
+
+// SignFielder 请求参数实现后签名时直接获取参与签名的参数值 不需要json序列化和反射
+type SignFielder interface {
+	// SignFields 将参与签名的参数值追加到 fields 后返回
+	// 不包括 app_id thirdparty_id sign 等不参与签名的参数 与json一致 omitempty 的数字为0时不追加 空字符串由签名方法跳过
+	SignFields(fields []string) []string
+}
+
+// appendSignInt 追加非0的数字
+func appendSignInt(fields []string, value int64) []string {
+	if value == 0 {
+		return fields
+	}
+	return append(fields, strconv.FormatInt(value, 10))
+}
+
+// signJSON 与json序列化结果一致的紧凑json 为空时返回空字符串 不参与签名
+func (e *ExpandOrderInfo) signJSON() string {
+	if e == nil {
+		return ""
+	}
+	buf := make([]byte, 0, 64)
+	buf = append(buf, `{"original_delivery_fee":`...)
+	buf = strconv.AppendInt(buf, int64(e.OriginalDeliveryFee), 10)
+	buf = append(buf, `,"actual_delivery_fee":`...)
+	buf = strconv.AppendInt(buf, int64(e.ActualDeliveryFee), 10)
+	buf = append(buf, '}')
+	return string(buf)
+}
+
+// SignFields 参与签名的参数值
+func (p CreateOrderParams) SignFields(fields []string) []string {
+	fields = append(fields, p.OutOrderNo, p.Subject, p.Body, p.CpExtra, p.NotifyUrl, p.StoreUid, p.MsgPage, p.LimitPayWay, p.ExpandOrderInfo.signJSON())
+	fields = appendSignInt(fields, p.TotalAmount)
+	fields = appendSignInt(fields, p.ValidTime)
+	return appendSignInt(fields, int64(p.DisableMsg))
+}
+
+// SignFields 参与签名的参数值
+func (p QueryOrderParams) SignFields(fields []string) []string {
+	return append(fields, p.OutOrderNo)
+}
+
+// SignFields 参与签名的参数值
+func (p CreateRefundParams) SignFields(fields []string) []string {
+	fields = append(fields, p.OutOrderNo, p.OutRefundNo, p.Reason, p.CpExtra, p.NotifyUrl, p.MsgPage)
+	fields = appendSignInt(fields, int64(p.RefundAmount))
+	return appendSignInt(fields, int64(p.DisableMsg))
+}
+
+// SignFields 参与签名的参数值
+func (p QueryRefundParams) SignFields(fields []string) []string {
+	return append(fields, p.OutRefundNo)
+}
+
+// SignFields 参与签名的参数值
+func (p SettleParams) SignFields(fields []string) []string {
+	return append(fields, p.OutSettleNo, p.OutOrderNo, p.SettleDesc, p.NotifyUrl, p.CpExtra, p.SettleParams, p.Finish)
+}
+
+// SignFields 参与签名的参数值
+func (p QuerySettleParams) SignFields(fields []string) []string {
+	return append(fields, p.OutSettleNo)
+}
+
+// SignFields 参与签名的参数值
+func (p UnsettleAmountParams) SignFields(fields []string) []string {
+	return append(fields, p.OutOrderNo, p.OutItemOrderNo)
+}
+
+// SignFields 参与签名的参数值
+func (p CreateReturnParams) SignFields(fields []string) []string {
+	fields = append(fields, p.OutSettleNo, p.SettleNo, p.OutReturnNo, p.MerchantUid, p.ReturnDesc, p.CpExtra)
+	return appendSignInt(fields, int64(p.ReturnAmount))
+}
+
+// SignFields 参与签名的参数值
+func (p QueryReturnParams) SignFields(fields []string) []string {
+	return append(fields, p.ReturnNo, p.OutReturnNo)
+}
+
+// SignFields 参与签名的参数值
+func (p QueryMerchantBalanceParams) SignFields(fields []string) []string {
+	return append(fields, p.MerchantUid, p.ChannelType, p.MerchantEntity)
+}
+
+// SignFields 参与签名的参数值
+func (p MerchantWithdrawParams) SignFields(fields []string) []string {
+	fields = append(fields, p.MerchantUid, p.ChannelType, p.OutOrderId, p.Callback, p.CpExtra)
+	fields = appendSignInt(fields, int64(p.WithdrawAmount))
+	return appendSignInt(fields, int64(p.MerchantEntity))
+}
+
+// SignFields 参与签名的参数值
+func (p QueryWithdrawOrderParams) SignFields(fields []string) []string {
+	return append(fields, p.MerchantUid, p.ChannelType, p.OutOrderId)
+}
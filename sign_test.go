@@ -0,0 +1,226 @@
+package douyin_openapi
+
+import (
+	"crypto/md5"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// officialRequestSign 抖音开放平台 担保支付 签名算法文档中的 Go demo RequestSign
+// 函数体原样复制 仅将 demo 中的包级常量 salt 改为参数 用于生成和校验 signGoldenVectors
+func officialRequestSign(paramsMap map[string]interface{}, salt string) string {
+	var paramsArr []string
+	for k, v := range paramsMap {
+		if k == "other_settle_params" || k == "app_id" || k == "sign" || k == "thirdparty_id" {
+			continue
+		}
+		value := strings.TrimSpace(fmt.Sprintf("%v", v))
+		if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") && len(value) > 1 {
+			value = value[1 : len(value)-1]
+		}
+		value = strings.TrimSpace(value)
+		if value == "" || value == "null" {
+			continue
+		}
+		paramsArr = append(paramsArr, value)
+	}
+
+	paramsArr = append(paramsArr, salt)
+	sort.Strings(paramsArr)
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(paramsArr, "&"))))
+}
+
+// signGoldenVectors 官方 Go demo RequestSign 对 demo 请求体计算的签名 params 为空时使用 demo 作为参数
+var signGoldenVectors = []struct {
+	name   string
+	params interface{}
+	demo   map[string]interface{}
+	salt   string
+	sign   string
+}{
+	{
+		name: "create_order",
+		params: CreateOrderParams{
+			AppId:        "tt07e3715e98c9aac0",
+			OutOrderNo:   "out_order_no_1",
+			TotalAmount:  10000000000,
+			Subject:      "测试订单",
+			Body:         "测试订单",
+			ValidTime:    172800,
+			NotifyUrl:    "https://example.com/notify",
+			ThirdpartyId: "tt_thirdparty",
+		},
+		demo: map[string]interface{}{
+			"app_id":        "tt07e3715e98c9aac0",
+			"out_order_no":  "out_order_no_1",
+			"total_amount":  10000000000,
+			"subject":       "测试订单",
+			"body":          "测试订单",
+			"valid_time":    172800,
+			"notify_url":    "https://example.com/notify",
+			"thirdparty_id": "tt_thirdparty",
+		},
+		salt: "your_payment_salt",
+		sign: "3c79fa8f237190e0546e2d3cbe9a238e",
+	},
+	{
+		name: "quoted_and_empty_values",
+		demo: map[string]interface{}{
+			"subject":             `  "quoted subject"  `,
+			"body":                "   ",
+			"cp_extra":            "null",
+			"disable_msg":         0,
+			"other_settle_params": `[{"merchant_uid":"1","amount":1}]`,
+			"sign":                "old_sign",
+			"app_id":              "tt07e3715e98c9aac0",
+		},
+		salt: "your_payment_salt",
+		sign: "0aec269bc0dd1e5ad4e2220349b27763",
+	},
+	{
+		name:   "query_order",
+		params: QueryOrderParams{AppId: "tt07e3715e98c9aac0", OutOrderNo: "out_order_no_1", ThirdpartyId: "tt_thirdparty"},
+		demo:   map[string]interface{}{"app_id": "tt07e3715e98c9aac0", "out_order_no": "out_order_no_1", "thirdparty_id": "tt_thirdparty"},
+		salt:   "your_payment_salt",
+		sign:   "f6655abc5b4eb8c7baaf3a282ad21436",
+	},
+}
+
+// signPlainVectors demo 使用 %v 格式化 嵌套对象和 nil 的结果与json请求体不一致 这些值按请求体中的紧凑json参与签名
+var signPlainVectors = []struct {
+	name   string
+	params interface{}
+	plain  string
+}{
+	{
+		name: "expand_order_info",
+		params: CreateOrderParams{
+			OutOrderNo:      "out_order_no_1",
+			TotalAmount:     100,
+			ExpandOrderInfo: &ExpandOrderInfo{OriginalDeliveryFee: 10, ActualDeliveryFee: 8},
+		},
+		plain: "100&out_order_no_1&salt&{\"original_delivery_fee\":10,\"actual_delivery_fee\":8}",
+	},
+	{
+		name: "nested_values",
+		params: map[string]interface{}{
+			"out_settle_no": "settle_1",
+			"settle_params": []map[string]interface{}{{"merchant_uid": "69xxx", "amount": 100}},
+			"finish":        true,
+			"ratio":         0.25,
+			"limit_pay_way": nil,
+		},
+		plain: "0.25&[{\"amount\":100,\"merchant_uid\":\"69xxx\"}]&salt&settle_1&true",
+	},
+}
+
+// 测试签名与官方demo算法一致
+func TestDouYinOpenApi_GenerateSign(t *testing.T) {
+	for _, vector := range signGoldenVectors {
+		t.Run(vector.name, func(t *testing.T) {
+			if sign := officialRequestSign(vector.demo, vector.salt); sign != vector.sign {
+				t.Fatalf("demo sign %s want %s", sign, vector.sign)
+			}
+			params := vector.params
+			if params == nil {
+				params = vector.demo
+			}
+			api := NewDouYinOpenApi(DouYinOpenApiConfig{AppId: "tt07e3715e98c9aac0", Salt: vector.salt})
+			if sign := api.GenerateSign(params); sign != vector.sign {
+				t.Fatalf("got sign %s want %s", sign, vector.sign)
+			}
+		})
+	}
+	for _, vector := range signPlainVectors {
+		t.Run(vector.name, func(t *testing.T) {
+			plain, err := signPlain(vector.params, "salt")
+			if err != nil || plain != vector.plain {
+				t.Fatalf("got plain %s error %v", plain, err)
+			}
+			api := NewDouYinOpenApi(DouYinOpenApiConfig{Salt: "salt"})
+			if sign := api.GenerateSign(vector.params); sign != md5Sign(plain) {
+				t.Fatalf("got sign %s", sign)
+			}
+		})
+	}
+}
+
+// signFielders 实现了 SignFielder 的请求参数
+var signFielders = []SignFielder{
+	CreateOrderParams{}, QueryOrderParams{}, CreateRefundParams{}, QueryRefundParams{},
+	SettleParams{}, QuerySettleParams{}, UnsettleAmountParams{}, CreateReturnParams{},
+	QueryReturnParams{}, QueryMerchantBalanceParams{}, MerchantWithdrawParams{}, QueryWithdrawOrderParams{},
+}
+
+// fillSignParams 给所有字段填充不同的值 新增字段未加入 SignFields 时签名不一致
+func fillSignParams(params SignFielder, seed int) SignFielder {
+	value := reflect.New(reflect.TypeOf(params)).Elem()
+	var fill func(v reflect.Value)
+	fill = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			seed++
+			field := v.Field(i)
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString(fmt.Sprintf(" value_%d 测试 ", seed))
+			case reflect.Int, reflect.Int64:
+				field.SetInt(int64(seed) * 1000000007)
+			case reflect.Struct:
+				fill(field)
+			case reflect.Ptr:
+				if field.Type().Elem().Kind() == reflect.Struct {
+					field.Set(reflect.New(field.Type().Elem()))
+					fill(field.Elem())
+				}
+			}
+		}
+	}
+	fill(value)
+	return value.Interface().(SignFielder)
+}
+
+// 测试 SignFields 的签名与json方式一致
+func TestDouYinOpenApi_SignFields(t *testing.T) {
+	for _, params := range signFielders {
+		for _, filled := range []SignFielder{params, fillSignParams(params, 0), fillSignParams(params, 100)} {
+			plain, err := signPlain(filled, "salt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sign, ok := fieldsSign(filled, "salt"); !ok || sign != md5Sign(plain) {
+				t.Fatalf("%T sign mismatch plain %s", filled, plain)
+			}
+		}
+	}
+	// 非法的utf8回退到json方式
+	params := QueryOrderParams{OutOrderNo: "order\xff"}
+	if _, ok := fieldsSign(params, "salt"); ok {
+		t.Fatalf("invalid utf8 should fall back")
+	}
+	plain, _ := signPlain(params, "salt")
+	api := NewDouYinOpenApi(DouYinOpenApiConfig{Salt: "salt"})
+	if api.GenerateSign(params) != md5Sign(plain) {
+		t.Fatalf("fallback sign mismatch")
+	}
+}
+
+// 对比json方式和 SignFields 方式的签名性能
+func BenchmarkDouYinOpenApi_GenerateSign(b *testing.B) {
+	params := fillSignParams(CreateOrderParams{}, 0)
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			plain, _ := signPlain(params, "salt")
+			_ = md5Sign(plain)
+		}
+	})
+	b.Run("fields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = fieldsSign(params, "salt")
+		}
+	})
+}
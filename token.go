@@ -0,0 +1,84 @@
+package douyin_openapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/HeartGarlic/douyin-openapi/util"
+	"net/http"
+)
+
+// TokenPlacement access_token 在请求中的位置
+type TokenPlacement int
+
+const (
+	TokenInBody   TokenPlacement = iota + 1 // 请求体的 access_token 字段
+	TokenInHeader                           // access-token 请求头
+)
+
+// tokenHeader 通过请求头传递token时的header名称
+const tokenHeader = "access-token"
+
+// tokenEndpoints 需要 access_token 的内置接口
+var tokenEndpoints = map[string]TokenPlacement{
+	orderV2Push: TokenInBody,
+}
+
+// PostJsonWithToken 请求需要 access_token 的接口 token 从 Config.AccessToken 获取并按 placement 注入
+func (d *DouYinOpenApi) PostJsonWithToken(api string, placement TokenPlacement, params interface{}, response interface{}) (err error) {
+	return d.PostJsonWithTokenCtx(context.Background(), api, placement, params, response)
+}
+
+// PostJsonWithTokenCtx 请求需要 access_token 的接口 支持传入context
+func (d *DouYinOpenApi) PostJsonWithTokenCtx(ctx context.Context, api string, placement TokenPlacement, params interface{}, response interface{}) (err error) {
+	return d.postWithToken(ctx, util.EndpointName(api), api, placement, params, response)
+}
+
+// postWithToken 注入 access_token 后发起请求 请求体中已经传入token时使用传入的token
+func (d *DouYinOpenApi) postWithToken(ctx context.Context, endpoint, api string, placement TokenPlacement, params interface{}, response interface{}) (err error) {
+	var body map[string]interface{}
+	var token string
+	switch placement {
+	case TokenInBody:
+		if body, err = util.JsonStructToMap(params); err != nil {
+			return
+		}
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		token, _ = body["access_token"].(string)
+	case TokenInHeader:
+	default:
+		return fmt.Errorf("douyin: unknown token placement %d", placement)
+	}
+	if token == "" {
+		if token, err = d.Config.AccessToken.GetAccessTokenContext(ctx); err != nil {
+			return
+		}
+	}
+	return d.withToken(ctx, token, func(token string) error {
+		if placement == TokenInHeader {
+			header := http.Header{}
+			header.Set(tokenHeader, token)
+			return d.doPost(ctx, endpoint, api, header, params, response)
+		}
+		body["access_token"] = token
+		return d.doPost(ctx, endpoint, api, nil, body, response)
+	})
+}
+
+// withToken 执行需要 access_token 的请求 抖音返回token无效时使缓存的token失效 重新获取后重试一次
+func (d *DouYinOpenApi) withToken(ctx context.Context, token string, call func(token string) error) error {
+	err := call(token)
+	if token == "" || !errors.Is(err, ErrTokenExpired) {
+		return err
+	}
+	if invalidateErr := d.Config.AccessToken.Invalidate(token); invalidateErr != nil {
+		return err
+	}
+	newToken, tokenErr := d.Config.AccessToken.GetAccessTokenContext(ctx)
+	if tokenErr != nil || newToken == token {
+		return err
+	}
+	return call(newToken)
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 错误分类 可以通过 errors.Is 判断 APIError 属于哪一类
+var (
+	ErrInvalidSign         = errors.New("douyin: invalid sign")         // 签名错误
+	ErrTokenExpired        = errors.New("douyin: access token expired") // access_token 过期或无效
+	ErrOrderNotFound       = errors.New("douyin: order not found")      // 订单不存在
+	ErrInsufficientBalance = errors.New("douyin: insufficient balance") // 余额不足
+	ErrRateLimited         = errors.New("douyin: rate limited")         // 请求频率超限
+	ErrSystemBusy          = errors.New("douyin: system busy")          // 系统繁忙
+)
+
+// APIError 抖音接口返回的业务错误 兼容 err_no/err_tips 和 err_code/err_msg 两种格式
+type APIError struct {
+	Endpoint string // 请求的接口
+	ErrNo    int    // 错误码
+	ErrTips  string // 错误信息
+	LogID    string // 抖音侧的日志id 排查问题时提供给抖音
+	RawBody  []byte // 原始返回值
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s error: %s %d", e.Endpoint, e.ErrTips, e.ErrNo)
+}
+
+// Is 判断错误的分类 用于 errors.Is(err, ErrTokenExpired) 等判断
+func (e *APIError) Is(target error) bool {
+	return target != nil && e.Class() == target
+}
+
+// Class 返回错误的分类 无法识别时返回 nil
+func (e *APIError) Class() error {
+	errCodeLock.RLock()
+	class, ok := errCodeClasses[e.ErrNo]
+	errCodeLock.RUnlock()
+	if ok {
+		return class
+	}
+	tips := strings.ToLower(e.ErrTips)
+	for _, keyword := range errTipsClasses {
+		for _, phrase := range keyword.phrases {
+			if containsPhrase(tips, phrase) {
+				return keyword.class
+			}
+		}
+	}
+	return nil
+}
+
+// errCodeLock 保护错误码分类表
+var errCodeLock sync.RWMutex
+
+// errCodeClasses 错误码和分类的对应关系
+var errCodeClasses = map[int]error{
+	-1:    ErrSystemBusy,   // 系统错误
+	2008:  ErrInvalidSign,  // 担保支付 签名校验失败
+	40002: ErrTokenExpired, // access_token 无效
+	40004: ErrTokenExpired, // access_token 过期
+	40005: ErrRateLimited,  // 请求太频繁
+}
+
+// errTipsClasses 错误码未登记时根据错误信息里的完整短语分类 按顺序匹配
+// 只收录明确的短语 避免 "access_token is required" "design" "app not exist" 之类的信息被误判
+var errTipsClasses = []struct {
+	phrases []string
+	class   error
+}{
+	{[]string{"access_token expired", "access_token invalid", "access token expired", "access token invalid", "access_token过期", "access_token无效"}, ErrTokenExpired},
+	{[]string{"签名错误", "签名校验失败", "验签失败", "invalid sign", "sign error", "signature error"}, ErrInvalidSign},
+	{[]string{"余额不足", "insufficient balance"}, ErrInsufficientBalance},
+	{[]string{"订单不存在", "order not exist", "order does not exist", "order not found"}, ErrOrderNotFound},
+	{[]string{"请求太频繁", "请求过于频繁", "频率超限", "too many requests", "rate limited", "rate limit exceeded"}, ErrRateLimited},
+	{[]string{"系统繁忙", "系统错误", "请稍后重试", "system busy", "internal error"}, ErrSystemBusy},
+}
+
+// containsPhrase 判断 text 是否包含完整的 phrase 英文短语两侧不能紧挨字母数字或下划线
+func containsPhrase(text, phrase string) bool {
+	for offset := 0; offset < len(text); {
+		index := strings.Index(text[offset:], phrase)
+		if index < 0 {
+			return false
+		}
+		start, end := offset+index, offset+index+len(phrase)
+		if (start == 0 || !isWordByte(text[start-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+// isWordByte 判断是否为英文单词字符
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// RegisterErrCode 登记错误码的分类 用于补充或覆盖内置的错误码
+func RegisterErrCode(errNo int, class error) {
+	errCodeLock.Lock()
+	defer errCodeLock.Unlock()
+	errCodeClasses[errNo] = class
+}
+
+// responseEnvelope 抖音接口返回值的公共部分
+type responseEnvelope struct {
+	ErrNo   int    `json:"err_no"`
+	ErrTips string `json:"err_tips"`
+	ErrCode int    `json:"err_code"`
+	ErrMsg  string `json:"err_msg"`
+	LogId   string `json:"log_id"`
+	Extra   struct {
+		LogId string `json:"logid"`
+	} `json:"extra"`
+}
+
+// EndpointName 获取接口名称 即请求地址的path部分
+func EndpointName(api string) string {
+	if u, err := url.Parse(api); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return api
+}
+
+// CheckResponse 检查接口返回值 业务失败时返回 *APIError 返回值不是json时返回解析错误
+func CheckResponse(endpoint string, body []byte) error {
+	var envelope responseEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("%s invalid response: %w", endpoint, err)
+	}
+	if envelope.ErrNo == 0 && envelope.ErrCode == 0 {
+		return nil
+	}
+	apiError := &APIError{
+		Endpoint: endpoint,
+		ErrNo:    envelope.ErrNo,
+		ErrTips:  envelope.ErrTips,
+		LogID:    envelope.LogId,
+		RawBody:  body,
+	}
+	if envelope.ErrNo == 0 {
+		apiError.ErrNo = envelope.ErrCode
+	}
+	// 交易系统的接口返回 err_no 和 err_msg
+	if apiError.ErrTips == "" {
+		apiError.ErrTips = envelope.ErrMsg
+	}
+	if apiError.LogID == "" {
+		apiError.LogID = envelope.Extra.LogId
+	}
+	return apiError
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultFlightTimeout 默认的单次获取超时时间
+const defaultFlightTimeout = 30 * time.Second
+
+// flightCall 一次正在进行的获取
+type flightCall struct {
+	done     chan struct{}
+	val      string
+	err      error
+	panicVal interface{} // fn panic 的值 发起者收到后重新 panic
+}
+
+// FlightGroup 合并相同key的并发获取 同一个key同时只有一个请求 不同key之间互不影响
+type FlightGroup struct {
+	Timeout time.Duration // 单次获取的超时时间 默认30秒 获取不受发起者 ctx 取消的影响
+
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do 执行 fn 相同key的并发调用共享同一次执行的结果 等待期间 ctx 结束时提前返回
+// fn 使用脱离调用方取消的 ctx 执行 发起者取消不会让其他等待者失败
+// fn panic 时发起者重新 panic 其他等待者收到错误
+func (g *FlightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (string, error)) (string, error) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	call, ok := g.calls[key]
+	leader := !ok
+	if leader {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(ctx, key, call, fn)
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-call.done:
+		if leader && call.panicVal != nil {
+			panic(call.panicVal)
+		}
+		return call.val, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// run 执行一次获取 fn panic 时等待者收到错误
+func (g *FlightGroup) run(ctx context.Context, key string, call *flightCall, fn func(ctx context.Context) (string, error)) {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultFlightTimeout
+	}
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, timeout)
+	defer func() {
+		cancel()
+		if r := recover(); r != nil {
+			call.val, call.err, call.panicVal = "", fmt.Errorf("douyin: fetch %s panic: %v", key, r), r
+		}
+		g.lock.Lock()
+		delete(g.calls, key)
+		g.lock.Unlock()
+		close(call.done)
+	}()
+	call.val, call.err = fn(ctx)
+}
+
+// detachedContext 保留父 ctx 的值 但不继承取消和超时
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+func (c detachedContext) Value(key interface{}) interface{}     { return c.parent.Value(key) }
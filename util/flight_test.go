@@ -0,0 +1,73 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试发起者取消后等待者仍然拿到结果
+func TestFlightGroup_LeaderCancel(t *testing.T) {
+	var group FlightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := group.Do(ctx, "key", func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			return "token", ctx.Err()
+		})
+		leaderErr <- err
+	}()
+	<-started
+	follower := make(chan string, 1)
+	go func() {
+		val, _ := group.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+			return "other", nil
+		})
+		follower <- val
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got a error %v", err)
+	}
+	close(release)
+	if val := <-follower; val != "token" {
+		t.Fatalf("got a value %s", val)
+	}
+}
+
+// 测试 fn panic 时发起者重新 panic 等待者收到错误
+func TestFlightGroup_Panic(t *testing.T) {
+	var group FlightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	leaderPanic := make(chan interface{}, 1)
+	go func() {
+		defer func() { leaderPanic <- recover() }()
+		_, _ = group.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	followerErr := make(chan error, 1)
+	go func() {
+		val, err := group.Do(context.Background(), "key", nil)
+		if err == nil && val == "" {
+			err = errors.New("empty token reported as success")
+		}
+		followerErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if r := <-leaderPanic; r != "boom" {
+		t.Fatalf("leader should re-panic, got %v", r)
+	}
+	if err := <-followerErr; err == nil || err.Error() == "empty token reported as success" {
+		t.Fatalf("got a error %v", err)
+	}
+}
@@ -2,43 +2,159 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
+// Doer 发起http请求的客户端接口 *http.Client 已实现该接口
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DefaultHttpClient 默认的http客户端 未指定客户端时使用
+var DefaultHttpClient Doer = NewHttpClient(0)
+
+// NewHttpClient 实例化一个带超时和连接池的http客户端 timeout 为0时使用默认的10秒
+func NewHttpClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   20,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
 // PostForm post form 数据请求
 func PostForm(uri string, obj url.Values) ([]byte, error) {
-	response, err := http.PostForm(uri, obj)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
+	return PostFormWithClient(DefaultHttpClient, uri, obj)
+}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get error : uri=%v , statusCode=%v", uri, response.StatusCode)
+// PostFormWithClient 使用指定的客户端 post form 数据请求
+func PostFormWithClient(client Doer, uri string, obj url.Values) ([]byte, error) {
+	return PostFormContext(context.Background(), client, uri, obj)
+}
+
+// PostFormContext 使用指定的客户端和context post form 数据请求
+func PostFormContext(ctx context.Context, client Doer, uri string, obj url.Values) ([]byte, error) {
+	if obj == nil {
+		obj = url.Values{}
 	}
-	return ioutil.ReadAll(response.Body)
+	return DoRequest(ctx, client, &Request{Endpoint: EndpointName(uri), Url: uri, Form: obj})
+}
+
+// GetContext 使用指定的客户端和context发起 get 请求
+func GetContext(ctx context.Context, client Doer, uri string) ([]byte, error) {
+	return DoRequest(ctx, client, &Request{Endpoint: EndpointName(uri), Method: http.MethodGet, Url: uri})
 }
 
 // PostJSON post json 数据请求
 func PostJSON(uri string, obj interface{}) ([]byte, error) {
-	marshal, err := json.Marshal(obj)
+	return PostJSONWithClient(DefaultHttpClient, uri, obj)
+}
+
+// PostJSONWithClient 使用指定的客户端 post json 数据请求
+func PostJSONWithClient(client Doer, uri string, obj interface{}) ([]byte, error) {
+	return PostJSONContext(context.Background(), client, uri, obj)
+}
+
+// PostJSONContext 使用指定的客户端和context post json 数据请求
+func PostJSONContext(ctx context.Context, client Doer, uri string, obj interface{}) ([]byte, error) {
+	return DoRequest(ctx, client, &Request{Endpoint: EndpointName(uri), Url: uri, Params: obj})
+}
+
+// DoRequest 使用指定的客户端发送请求 设置了 Form 时发送表单 否则发送 json
+func DoRequest(ctx context.Context, client Doer, req *Request) ([]byte, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var body io.Reader
+	contentType := "application/json;charset=utf-8"
+	if method == http.MethodGet {
+		contentType = ""
+	} else if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	} else if req.Form != nil {
+		body = strings.NewReader(req.Form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		marshal, err := json.Marshal(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(marshal)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, req.Url, body)
 	if err != nil {
 		return nil, err
 	}
-	response, err := http.Post(uri, "application/json;charset=utf-8", bytes.NewBuffer(marshal))
+	for key, values := range req.Header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	respBody, header, err := doRequest(client, request)
+	req.ResponseHeader = header
+	return respBody, err
+}
+
+// doRequest 执行请求并读取返回值和响应头
+func doRequest(client Doer, request *http.Request) ([]byte, http.Header, error) {
+	if client == nil {
+		client = DefaultHttpClient
+	}
+	response, err := client.Do(request)
 	if err != nil {
-		return nil, err
+		// 错误信息中的请求地址可能包含 secret ticket 等查询参数
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactQuery(urlErr.URL)
+		}
+		return nil, nil, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get error : uri=%v , statusCode=%v", uri, response.StatusCode)
+		return nil, response.Header, &HttpStatusError{Uri: redactQuery(request.URL.String()), StatusCode: response.StatusCode}
 	}
-	return ioutil.ReadAll(response.Body)
+	body, err := ioutil.ReadAll(response.Body)
+	return body, response.Header, err
+}
+
+// HttpStatusError http状态码不是200时返回的错误
+type HttpStatusError struct {
+	Uri        string // 请求地址 敏感的查询参数已脱敏
+	StatusCode int
+}
+
+// Error 实现 error 接口
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("http get error : uri=%v , statusCode=%v", e.Uri, e.StatusCode)
 }
 
 // JsonStructToMap ...
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Request 一次接口请求 拦截器可以修改请求地址 参数和请求头
+type Request struct {
+	Endpoint string      // 接口名称 即接口路径
+	Method   string      // 请求方法 为空时为 POST 为 GET 时不发送请求体
+	Url      string      // 请求地址
+	Params   interface{} // 已签名的请求参数 发送时序列化为json
+	Form     url.Values  // 表单参数 不为空时以表单方式发送 忽略 Params
+	Body     []byte      // 已序列化的json请求体 不为空时直接发送 忽略 Params 和 Form 用于签名请求体的拦截器
+	Header   http.Header // 额外的请求头
+
+	ResponseHeader http.Header // 返回的响应头 请求成功后由 DoRequest 填充
+}
+
+// Invoker 执行请求并返回原始的返回值
+type Invoker func(ctx context.Context, req *Request) ([]byte, error)
+
+// Interceptor 请求拦截器 调用 invoker 继续执行请求 不调用则直接使用拦截器的返回值
+type Interceptor func(ctx context.Context, req *Request, invoker Invoker) ([]byte, error)
+
+// NewInvoker 使用指定的客户端实例化一个发送请求的 Invoker
+func NewInvoker(client Doer) Invoker {
+	return func(ctx context.Context, req *Request) ([]byte, error) {
+		return DoRequest(ctx, client, req)
+	}
+}
+
+// ChainInterceptors 将拦截器串联起来 第一个拦截器在最外层
+func ChainInterceptors(invoker Invoker, interceptors ...Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req *Request) ([]byte, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return invoker
+}
@@ -0,0 +1,258 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String 日志级别名称
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Field 日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger 结构化日志接口 可以适配 zap logrus 等日志库
+type Logger interface {
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// NopLogger 不输出任何日志
+type NopLogger struct{}
+
+// Log 实现 Logger 接口
+func (NopLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {}
+
+// StdLogger 使用标准库 log 输出日志
+type StdLogger struct {
+	Logger   *log.Logger // 为空时使用 log 包默认的 Logger
+	MinLevel Level       // 低于该级别的日志不输出
+}
+
+// NewStdLogger 实例化一个标准库日志
+func NewStdLogger(logger *log.Logger, minLevel Level) *StdLogger {
+	return &StdLogger{Logger: logger, MinLevel: minLevel}
+}
+
+// Log 实现 Logger 接口 输出格式为 LEVEL msg key=value ...
+func (s *StdLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	if level < s.MinLevel {
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString(level.String())
+	builder.WriteString(" ")
+	builder.WriteString(msg)
+	for _, field := range fields {
+		builder.WriteString(fmt.Sprintf(" %s=%v", field.Key, field.Value))
+	}
+	if s.Logger == nil {
+		log.Print(builder.String())
+		return
+	}
+	s.Logger.Print(builder.String())
+}
+
+// redactLogger 输出前对字段脱敏的日志
+type redactLogger struct {
+	logger Logger
+}
+
+// NewRedactLogger 包装一个日志 输出前自动对 secret sign session_key access_token Salt Token 等字段脱敏
+func NewRedactLogger(logger Logger) Logger {
+	if logger == nil {
+		return NopLogger{}
+	}
+	if _, ok := logger.(*redactLogger); ok {
+		return logger
+	}
+	if _, ok := logger.(NopLogger); ok {
+		return logger
+	}
+	return &redactLogger{logger: logger}
+}
+
+// Log 实现 Logger 接口
+func (r *redactLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	redacted := make([]Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = Field{Key: field.Key, Value: Redact(field.Key, field.Value)}
+	}
+	r.logger.Log(ctx, level, msg, redacted...)
+}
+
+// redactedValue 脱敏后的值
+const redactedValue = "******"
+
+// sensitiveKeys 需要脱敏的字段 忽略大小写和下划线
+var sensitiveKeys = map[string]bool{
+	"secret":            true,
+	"appsecret":         true,
+	"clientsecret":      true,
+	"sign":              true,
+	"sessionkey":        true,
+	"accesstoken":       true,
+	"refreshtoken":      true,
+	"salt":              true,
+	"token":             true,
+	"msgsignature":      true,
+	"code":              true,
+	"authorizationcode": true,
+	"ticket":            true,
+	"componentticket":   true,
+}
+
+// IsSensitiveKey 判断字段是否需要脱敏 包括 secret sign session_key access_token Salt Token code ticket 等
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	return sensitiveKeys[key] || strings.HasSuffix(key, "secret") || strings.HasSuffix(key, "accesstoken") || strings.HasSuffix(key, "refreshtoken")
+}
+
+// queryParamPattern 文本中的url查询参数 例如错误信息中的请求地址
+var queryParamPattern = regexp.MustCompile(`([?&])([^=&?#\s"']+)=([^&#\s"']*)`)
+
+// redactQuery 对文本中url查询参数的敏感值脱敏
+func redactQuery(text string) string {
+	if !strings.Contains(text, "=") {
+		return text
+	}
+	return queryParamPattern.ReplaceAllStringFunc(text, func(param string) string {
+		match := queryParamPattern.FindStringSubmatch(param)
+		if !IsSensitiveKey(match[2]) {
+			return param
+		}
+		return match[1] + match[2] + "=" + redactedValue
+	})
+}
+
+// Redact 对日志字段脱敏 敏感字段直接替换 结构体 map 和 json 中的敏感字段替换后返回 错误信息中url的敏感参数替换后返回
+func Redact(key string, value interface{}) interface{} {
+	if IsSensitiveKey(key) {
+		return redactedValue
+	}
+	switch v := value.(type) {
+	case nil, bool, int, int64, float64, time.Duration:
+		return value
+	case error:
+		if text := redactQuery(v.Error()); text != v.Error() {
+			return text
+		}
+		return value
+	case string:
+		return v
+	case []byte:
+		return RedactJSON(v)
+	}
+	marshal, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return RedactJSON(marshal)
+}
+
+// RedactJSON 对 json 中的敏感字段脱敏 不是 json 时原样返回
+func RedactJSON(body []byte) string {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return string(body)
+	}
+	marshal, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return string(body)
+	}
+	return string(marshal)
+}
+
+// redactValue 递归替换敏感字段
+func redactValue(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if IsSensitiveKey(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return data
+}
+
+// OutNoFields 提取请求参数中 out_ 开头的开发者单号 用于日志关联
+func OutNoFields(params interface{}) []Field {
+	paramsMap, err := JsonStructToMap(params)
+	if err != nil {
+		return nil
+	}
+	var fields []Field
+	for key, value := range paramsMap {
+		if strings.HasPrefix(key, "out_") {
+			fields = append(fields, Field{Key: key, Value: value})
+		}
+	}
+	return fields
+}
+
+// LogInterceptor 记录每次请求的接口 开发者单号 耗时和错误 请求和返回值脱敏后以 debug 级别输出
+func LogInterceptor(logger Logger) Interceptor {
+	logger = NewRedactLogger(logger)
+	return func(ctx context.Context, req *Request, invoker Invoker) ([]byte, error) {
+		start := time.Now()
+		body, err := invoker(ctx, req)
+		fields := []Field{{Key: "endpoint", Value: req.Endpoint}}
+		fields = append(fields, OutNoFields(req.Params)...)
+		fields = append(fields, Field{Key: "latency", Value: time.Since(start)})
+		if err != nil {
+			fields = append(fields, Field{Key: "error", Value: err})
+			logger.Log(ctx, LevelError, "douyin request failed", fields...)
+		} else {
+			logger.Log(ctx, LevelInfo, "douyin request", fields...)
+		}
+		var request interface{} = req.Params
+		if req.Form != nil {
+			request = req.Form
+		}
+		logger.Log(ctx, LevelDebug, "douyin request detail",
+			Field{Key: "endpoint", Value: req.Endpoint},
+			Field{Key: "request", Value: request},
+			Field{Key: "response", Value: body},
+		)
+		return body, err
+	}
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 测试字段和错误信息中url查询参数的脱敏
+func TestRedact(t *testing.T) {
+	for _, key := range []string{"code", "ticket", "component_ticket", "authorization_code", "component_appsecret", "authorizer_refresh_token"} {
+		if Redact(key, "value") != redactedValue {
+			t.Fatalf("%s should be redacted", key)
+		}
+	}
+	if Redact("out_order_no", "value") != "value" {
+		t.Fatalf("out_order_no should not be redacted")
+	}
+	err := &url.Error{Op: "Get", URL: "https://open.microapp.bytedance.com/openapi/v1/auth/tp/token?component_appid=tt_app&component_appsecret=SECRET&component_ticket=TICKET", Err: errors.New("connection refused")}
+	redacted, _ := Redact("error", err).(string)
+	if strings.Contains(redacted, "SECRET") || strings.Contains(redacted, "TICKET") || !strings.Contains(redacted, "component_appid=tt_app") {
+		t.Fatalf("got %s", redacted)
+	}
+	plain := errors.New("douyin: order not found")
+	if Redact("error", plain) != plain {
+		t.Fatalf("error without sensitive query should be kept")
+	}
+}
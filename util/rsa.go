@@ -0,0 +1,223 @@
+package util
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	AuthorizationHeader = "Byte-Authorization" // 请求签名的请求头
+	TimestampHeader     = "Byte-Timestamp"     // 返回值和回调签名的时间戳请求头
+	NonceHeader         = "Byte-Nonce-Str"     // 返回值和回调签名的随机串请求头
+	SignatureHeader     = "Byte-Signature"     // 返回值和回调的签名请求头
+)
+
+// rsaAuthorizationType 签名算法
+const rsaAuthorizationType = "SHA256-RSA2048"
+
+// defaultTimestampTolerance 默认允许的时间戳误差
+const defaultTimestampTolerance = 5 * time.Minute
+
+var (
+	ErrSignatureMissing = errors.New("douyin: signature headers missing")   // 返回值或回调没有签名
+	ErrSignatureExpired = errors.New("douyin: signature timestamp expired") // 签名的时间戳超出允许的误差 可能是重放的请求
+)
+
+// RSASigner 通用交易系统的 RSA-SHA256 签名和验签
+type RSASigner struct {
+	AppId              string          // 小程序的 app_id
+	KeyVersion         string          // 应用公钥的版本号
+	PrivateKey         *rsa.PrivateKey // 应用私钥 用于请求签名
+	PlatformPublicKey  *rsa.PublicKey  // 平台公钥 用于验证返回值和回调 为空时不验证返回值
+	TimestampTolerance time.Duration   // 验签时 Byte-Timestamp 与本地时间允许的误差 默认5分钟 小于0时不校验
+}
+
+// NewRSASigner 使用 PEM 格式的应用私钥和平台公钥实例化签名器 platformPublicKey 为空时不验证返回值
+func NewRSASigner(appId, keyVersion string, privateKey, platformPublicKey []byte) (*RSASigner, error) {
+	key, err := ParseRSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer := &RSASigner{AppId: appId, KeyVersion: keyVersion, PrivateKey: key}
+	if len(platformPublicKey) > 0 {
+		if signer.PlatformPublicKey, err = ParseRSAPublicKey(platformPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+// ParseRSAPrivateKey 解析 PKCS#1 或 PKCS#8 格式的 PEM 私钥
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("douyin: invalid private key pem")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("douyin: private key is %T not rsa", key)
+	}
+	return rsaKey, nil
+}
+
+// ParseRSAPublicKey 解析 PKIX 或 PKCS#1 格式的 PEM 公钥
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("douyin: invalid public key pem")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("douyin: public key is %T not rsa", key)
+	}
+	return rsaKey, nil
+}
+
+// Sign 对 method\nuri\ntimestamp\nnonce\nbody\n 签名 返回 base64 编码的签名
+func (s *RSASigner) Sign(method, uri, timestamp, nonce string, body []byte) (string, error) {
+	plain := method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	hashed := sha256.Sum256([]byte(plain))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// Authorization 生成 Byte-Authorization 请求头 uri 为请求路径和查询参数
+func (s *RSASigner) Authorization(method, uri string, body []byte) (string, error) {
+	nonce, err := rsaNonce()
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, err := s.Sign(method, uri, timestamp, nonce, body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s appid="%s",nonce_str="%s",timestamp="%s",key_version="%s",signature="%s"`,
+		rsaAuthorizationType, s.AppId, nonce, timestamp, s.KeyVersion, signature), nil
+}
+
+// Verify 使用平台公钥验证 timestamp\nnonce\nbody\n 的签名 时间戳超出允许的误差时返回 ErrSignatureExpired
+func (s *RSASigner) Verify(timestamp, nonce string, body []byte, signature string) error {
+	if s.PlatformPublicKey == nil {
+		return errors.New("douyin: platform public key is need")
+	}
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+	if err := s.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	return rsa.VerifyPKCS1v15(s.PlatformPublicKey, crypto.SHA256, hashed[:], raw)
+}
+
+// checkTimestamp 检查时间戳与本地时间的误差 防止重放
+func (s *RSASigner) checkTimestamp(timestamp string) error {
+	tolerance := s.TimestampTolerance
+	if tolerance < 0 {
+		return nil
+	}
+	if tolerance == 0 {
+		tolerance = defaultTimestampTolerance
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("douyin: invalid signature timestamp %q", timestamp)
+	}
+	if diff := time.Since(time.Unix(unix, 0)); diff > tolerance || diff < -tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// VerifyHeader 使用 Byte-Timestamp Byte-Nonce-Str Byte-Signature 请求头验证返回值或回调
+func (s *RSASigner) VerifyHeader(header http.Header, body []byte) error {
+	return s.Verify(header.Get(TimestampHeader), header.Get(NonceHeader), body, header.Get(SignatureHeader))
+}
+
+// Interceptor 请求拦截器 序列化请求体后添加 Byte-Authorization 请求头 设置了平台公钥时验证返回值的签名
+func (s *RSASigner) Interceptor() Interceptor {
+	return func(ctx context.Context, req *Request, invoker Invoker) ([]byte, error) {
+		method := req.Method
+		if method == "" {
+			method = http.MethodPost
+		}
+		// 签名的请求体需要与发送的完全一致 json 请求体序列化后通过 Body 发送
+		payload := req.Body
+		if payload == nil && method != http.MethodGet {
+			if req.Form != nil {
+				payload = []byte(req.Form.Encode())
+			} else {
+				marshal, err := json.Marshal(req.Params)
+				if err != nil {
+					return nil, err
+				}
+				req.Body, payload = marshal, marshal
+			}
+		}
+		u, err := url.Parse(req.Url)
+		if err != nil {
+			return nil, err
+		}
+		authorization, err := s.Authorization(method, u.RequestURI(), payload)
+		if err != nil {
+			return nil, err
+		}
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(AuthorizationHeader, authorization)
+		body, err := invoker(ctx, req)
+		if err != nil || s.PlatformPublicKey == nil {
+			return body, err
+		}
+		if err = s.VerifyHeader(req.ResponseHeader, body); err != nil {
+			return nil, fmt.Errorf("douyin: verify %s response: %w", req.Endpoint, err)
+		}
+		return body, nil
+	}
+}
+
+// rsaNonce 生成32位随机串
+func rsaNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
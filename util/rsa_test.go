@@ -0,0 +1,95 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 测试 PKCS#1 PKCS#8 私钥和 PKCS#1 PKIX 公钥的解析 以及非 RSA 密钥的错误
+func TestRSASigner_ParseKey(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	pkix, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		if parsed, err := ParseRSAPrivateKey(pem.EncodeToMemory(block)); err != nil || !parsed.Equal(key) {
+			t.Fatalf("%s got a error %v", block.Type, err)
+		}
+	}
+	for _, block := range []*pem.Block{
+		{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)},
+		{Type: "PUBLIC KEY", Bytes: pkix},
+	} {
+		if parsed, err := ParseRSAPublicKey(pem.EncodeToMemory(block)); err != nil || !parsed.Equal(&key.PublicKey) {
+			t.Fatalf("%s got a error %v", block.Type, err)
+		}
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPrivate, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	ecPublic, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if _, err := ParseRSAPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPrivate})); err == nil {
+		t.Fatalf("ecdsa private key should fail")
+	}
+	if _, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPublic})); err == nil {
+		t.Fatalf("ecdsa public key should fail")
+	}
+	if _, err := ParseRSAPrivateKey([]byte("not pem")); err == nil {
+		t.Fatalf("invalid pem should fail")
+	}
+}
+
+// 测试返回值验签 缺少签名和时间戳超出误差
+func TestRSASigner_Verify(t *testing.T) {
+	platformKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signer := &RSASigner{PlatformPublicKey: &platformKey.PublicKey}
+	body := []byte(`{"err_no":0}`)
+	sign := func(timestamp string) string {
+		hashed := sha256.Sum256([]byte(timestamp + "\nnonce\n" + string(body) + "\n"))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, platformKey, crypto.SHA256, hashed[:])
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set(TimestampHeader, now)
+	header.Set(NonceHeader, "nonce")
+	header.Set(SignatureHeader, sign(now))
+	if err := signer.VerifyHeader(header, body); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	if err := signer.VerifyHeader(header, []byte(`{"err_no":1}`)); err == nil {
+		t.Fatalf("tampered body should fail")
+	}
+	header.Del(SignatureHeader)
+	if err := signer.VerifyHeader(header, body); !errors.Is(err, ErrSignatureMissing) {
+		t.Fatalf("got a error %v", err)
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	if err := signer.Verify(stale, "nonce", body, sign(stale)); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("got a error %v", err)
+	}
+	signer.TimestampTolerance = time.Hour
+	if err := signer.Verify(stale, "nonce", body, sign(stale)); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+	signer.TimestampTolerance = -1
+	if err := signer.Verify("1700000000", "nonce", body, sign("1700000000")); err != nil {
+		t.Fatalf("got a error %v", err)
+	}
+}